@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"os"
+
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/models"
+	"github.com/urfave/cli/v2"
+)
+
+const URL_ENV_NAME = "CONFIG_URL"
+
+// Flags of the config source, shared by all the commands that read the config
+var configSourceFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "file",
+		Usage: "YAML file containing configuration",
+	},
+	&cli.StringFlag{
+		Name:  "url",
+		Usage: "URL containing configuration yaml file",
+	},
+	&cli.BoolFlag{
+		Name:  "stdin",
+		Usage: "Read configuration from stdin",
+	},
+}
+
+// Returns the config source flags, the url env variable is used if the url flag is not provided
+func getConfigSource(c *cli.Context) (readStdin bool, filePath, url string) {
+	url = c.String("url")
+
+	// given flag overwrites env variable
+	if urlEnvValue := os.Getenv(URL_ENV_NAME); urlEnvValue != "" {
+		if url == "" {
+			url = urlEnvValue
+		} else {
+			logger.Warnf("Effect of env variable %s overwritten by provided url flag", URL_ENV_NAME)
+		}
+	}
+
+	return c.Bool("stdin"), c.String("file"), url
+}
+
+// Returns the config source and uri with the same precedence as the start command, and the name of the source to print
+// Source is empty if no config is provided
+func getConfigSourceURI(c *cli.Context) (source, uri, name string) {
+	readStdin, filePath, url := getConfigSource(c)
+
+	switch {
+	case readStdin:
+		return models.SOURCE_STDIN, "", "stdin"
+	case filePath != "":
+		return models.SOURCE_FILE, filePath, filePath
+	case url != "":
+		return models.SOURCE_URL, url, url
+	}
+
+	return "", "", ""
+}
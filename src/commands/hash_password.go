@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/passwords"
+	"github.com/urfave/cli/v2"
+)
+
+var HashPasswordCommand = &cli.Command{
+	Name:      "hash-password",
+	Usage:     "hashes a password to be used in basic auth, or a key to be used as an API key, the value is read from stdin if not provided",
+	ArgsUsage: "[password]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "algorithm",
+			Aliases: []string{"a"},
+			Usage:   fmt.Sprintf("hashing algorithm of passwords, one of (%s)", strings.Join(passwords.Algorithms, ", ")),
+			Value:   passwords.ALGORITHM_BCRYPT,
+		},
+		&cli.StringFlag{
+			Name:  "for",
+			Usage: "what the hash is used for, one of (basic-auth, api-key); API keys are hashed with unsalted sha256 and must be long random values",
+			Value: "basic-auth",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		logger.ResetLoggersFlags()
+
+		password := c.Args().First()
+
+		// Reading from stdin keeps the password out of the shell history
+		if password == "" {
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("could not read password from stdin: %w", err)
+			}
+
+			password = strings.TrimRight(line, "\r\n")
+		}
+
+		if password == "" {
+			return fmt.Errorf("password cannot be empty")
+		}
+
+		switch c.String("for") {
+		case "basic-auth":
+			hashed, err := passwords.Hash(password, c.String("algorithm"))
+			if err != nil {
+				return err
+			}
+
+			logger.Std.Println(hashed)
+
+		case "api-key":
+			if c.IsSet("algorithm") {
+				return fmt.Errorf(`"--algorithm" cannot be used for API keys, they are always hashed with sha256`)
+			}
+
+			logger.Std.Println(passwords.HashKey(password))
+
+		default:
+			return fmt.Errorf(`unknown "--for" value %q, must be one of: basic-auth, api-key`, c.String("for"))
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"time"
+
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/protocol"
+	"github.com/urfave/cli/v2"
+)
+
+var LogLevelCommand = &cli.Command{
+	Name:      "log-level",
+	Usage:     "prints or changes the log level of the running server, one of (debug, info, warn, error)",
+	ArgsUsage: "[level]",
+	Flags:     []cli.Flag{socketFlag},
+	Action: func(c *cli.Context) error {
+		logger.ResetLoggersFlags()
+
+		args := protocol.LogLevelArgs{Level: c.Args().First()}
+
+		response, err := callSocketStringCommand(c.String("socket"), protocol.COMMAND_LOG_LEVEL, args, 2*time.Second)
+		if err != nil {
+			return err
+		}
+
+		logger.Std.Println(response)
+
+		return nil
+	},
+}
@@ -3,11 +3,10 @@ package commands
 import (
 	"fmt"
 	"io"
-	"net"
 	"time"
 
 	"github.com/AmrSaber/redirector/src/lib/logger"
-	"github.com/AmrSaber/redirector/src/utils"
+	"github.com/AmrSaber/redirector/src/lib/protocol"
 	"github.com/urfave/cli/v2"
 )
 
@@ -15,6 +14,7 @@ var PingCommand = &cli.Command{
 	Name:  "ping",
 	Usage: "pings the server",
 	Flags: []cli.Flag{
+		socketFlag,
 		&cli.BoolFlag{
 			Name:    "quiet",
 			Aliases: []string{"q"},
@@ -30,30 +30,17 @@ var PingCommand = &cli.Command{
 			logger.Err.SetOutput(io.Discard)
 		}
 
-		conn, err := net.Dial("unix", utils.SOCKET_PATH)
+		response, err := callSocketStringCommand(c.String("socket"), protocol.COMMAND_PING, nil, 2*time.Second)
 		if err != nil {
-			return fmt.Errorf("server not running")
+			return err
 		}
 
-		defer conn.Close()
-		conn.SetDeadline(time.Now().Add(2 * time.Second))
-
-		_, err = conn.Write([]byte(utils.SOCKET_MESSAGE_PING + "\n"))
-		if err != nil {
-			return fmt.Errorf("error writing to socket: %w", err)
-		}
-
-		response, err := io.ReadAll(conn)
-		if err != nil {
-			return fmt.Errorf("error reading from socket: %w", err)
-		}
-
-		if string(response) != "PONG" {
+		if response != "PONG" {
 			return fmt.Errorf("unexpected response %q", response)
 		}
 
 		if !quiet {
-			logger.Std.Println(string(response))
+			logger.Std.Println(response)
 		}
 
 		return nil
@@ -0,0 +1,33 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/protocol"
+	"github.com/AmrSaber/redirector/src/utils"
+	"github.com/urfave/cli/v2"
+)
+
+var ReloadCommand = &cli.Command{
+	Name:  "reload",
+	Usage: "reloads the configuration of the running server",
+	Flags: []cli.Flag{socketFlag},
+	Action: func(c *cli.Context) error {
+		logger.ResetLoggersFlags()
+
+		// Loading from URL can take a while
+		response, err := callSocketStringCommand(c.String("socket"), protocol.COMMAND_RELOAD, nil, utils.SOCKET_RELOAD_TIMEOUT)
+		if err != nil {
+			return fmt.Errorf("could not reload config: %w", err)
+		}
+
+		if response != "OK" {
+			return fmt.Errorf("unexpected response %q", response)
+		}
+
+		logger.Std.Println(response)
+
+		return nil
+	},
+}
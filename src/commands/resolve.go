@@ -0,0 +1,211 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/config"
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/protocol"
+	"github.com/AmrSaber/redirector/src/models"
+	"github.com/AmrSaber/redirector/src/servers"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var ResolveCommand = &cli.Command{
+	Name:      "resolve",
+	Usage:     "prints where the given URLs go and which rules match them, using the given config or the rules of the running server",
+	ArgsUsage: "[url...]",
+	Flags: append(slices.Clone(configSourceFlags),
+		socketFlag,
+		&cli.GenericFlag{
+			Name:    "header",
+			Aliases: []string{"H"},
+			Usage:   `header to send with the URLs in the form of "Name: value", can be repeated`,
+			Value:   &repeatedValue{},
+		},
+		&cli.StringFlag{
+			Name:    "user",
+			Aliases: []string{"u"},
+			Usage:   `basic auth credentials to send with the URLs in the form of "username:password"`,
+		},
+		&cli.StringFlag{
+			Name:    "batch",
+			Aliases: []string{"b"},
+			Usage:   "file with a URL per line, optionally followed by the expected target URL or status code, the command fails if any expectation is not met",
+		},
+	),
+	Action: func(c *cli.Context) error {
+		logger.ResetLoggersFlags()
+
+		headers, err := getResolveHeaders(c)
+		if err != nil {
+			return err
+		}
+
+		resolve, err := getResolver(c, headers)
+		if err != nil {
+			return err
+		}
+
+		if batchPath := c.String("batch"); batchPath != "" {
+			return resolveBatch(batchPath, resolve)
+		}
+
+		if c.Args().Len() == 0 {
+			return fmt.Errorf("no URL provided")
+		}
+
+		for _, url := range c.Args().Slice() {
+			resolution, err := resolve(url)
+			if err != nil {
+				return err
+			}
+
+			out, _ := yaml.Marshal([]models.Resolution{resolution})
+			logger.Std.Print(string(out))
+		}
+
+		return nil
+	},
+}
+
+// Flag value that can be repeated, unlike string slice flags the values are not split on commas
+type repeatedValue []string
+
+func (value *repeatedValue) Set(item string) error {
+	*value = append(*value, item)
+	return nil
+}
+
+func (value *repeatedValue) String() string {
+	return strings.Join(*value, ", ")
+}
+
+func getResolveHeaders(c *cli.Context) (map[string][]string, error) {
+	headers := map[string][]string{}
+
+	if value, ok := c.Generic("header").(*repeatedValue); ok {
+		for _, header := range *value {
+			name, headerValue, found := strings.Cut(header, ":")
+			if !found || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf(`invalid header %q, expected "Name: value"`, header)
+			}
+
+			name = strings.TrimSpace(name)
+			headers[name] = append(headers[name], strings.TrimSpace(headerValue))
+		}
+	}
+
+	if user := c.String("user"); user != "" {
+		headers["Authorization"] = []string{"Basic " + base64.StdEncoding.EncodeToString([]byte(user))}
+	}
+
+	return headers, nil
+}
+
+// Returns a function that resolves URLs using the given config, or the running server if no config is given
+func getResolver(c *cli.Context, headers map[string][]string) (func(url string) (models.Resolution, error), error) {
+	source, uri, _ := getConfigSourceURI(c)
+
+	if source == "" {
+		socketPath := c.String("socket")
+
+		return func(url string) (models.Resolution, error) {
+			var resolution models.Resolution
+			args := protocol.ResolveArgs{URL: url, Headers: headers}
+
+			err := callSocketCommand(socketPath, protocol.COMMAND_RESOLVE, args, 5*time.Second, func(data json.RawMessage) error {
+				return json.Unmarshal(data, &resolution)
+			})
+
+			return resolution, err
+		}, nil
+	}
+
+	// Only the rules are needed, so the config is not applied and a config with failing tests can still be resolved against
+	rules, err := config.LoadConfigRules(source, uri)
+	if err != nil {
+		return nil, fmt.Errorf("could not load config: %w", err)
+	}
+
+	return func(url string) (models.Resolution, error) {
+		req, err := config.NewResolveRequest(url, headers)
+		if err != nil {
+			return models.Resolution{}, err
+		}
+
+		return servers.ResolveRequest(rules, req), nil
+	}, nil
+}
+
+// Resolves the URLs of the batch file and checks their expectations, empty lines and lines starting with # are skipped
+func resolveBatch(batchPath string, resolve func(url string) (models.Resolution, error)) error {
+	file, err := os.Open(batchPath)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	failures, checks := 0, 0
+	scanner := bufio.NewScanner(file)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if len(fields) > 2 {
+			return fmt.Errorf("%s:%d: expected a URL and an optional expectation, found %d fields", batchPath, lineNumber, len(fields))
+		}
+
+		resolution, err := resolve(fields[0])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", batchPath, lineNumber, err)
+		}
+
+		outcome := resolution.GetOutcome()
+		if len(fields) == 1 {
+			logger.Std.Printf("     %s -> %s\n", fields[0], outcome)
+			continue
+		}
+
+		checks++
+		expected := fields[1]
+
+		// Status code expectations are checked against the status even if the request is passed on
+		got := outcome
+		if _, err := strconv.Atoi(expected); err == nil {
+			got = strconv.Itoa(resolution.Status)
+		}
+
+		if got == expected {
+			logger.Std.Printf("ok   %s -> %s\n", fields[0], got)
+		} else {
+			failures++
+			logger.Std.Printf("FAIL %s -> %s, expected %s (%s:%d)\n", fields[0], got, expected, batchPath, lineNumber)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	logger.Std.Printf("\n%d passed, %d failed\n", checks-failures, failures)
+
+	if failures > 0 {
+		return cli.Exit("", 1)
+	}
+
+	return nil
+}
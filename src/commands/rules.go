@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/protocol"
+	"github.com/AmrSaber/redirector/src/models"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var RulesCommand = &cli.Command{
+	Name:  "rules",
+	Usage: "prints the active redirection rules of the running server",
+	Flags: []cli.Flag{socketFlag},
+	Action: func(c *cli.Context) error {
+		logger.ResetLoggersFlags()
+
+		// Rules are streamed one by one, print each as a yaml list item as soon as it's received
+		return callSocketCommand(c.String("socket"), protocol.COMMAND_RULES, nil, 10*time.Second, func(data json.RawMessage) error {
+			var redirect models.Redirect
+			if err := json.Unmarshal(data, &redirect); err != nil {
+				return err
+			}
+
+			out, _ := yaml.Marshal([]models.Redirect{redirect})
+			logger.Std.Print(string(out))
+
+			return nil
+		})
+	},
+}
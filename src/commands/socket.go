@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/lib/protocol"
+	"github.com/AmrSaber/redirector/src/utils"
+	"github.com/urfave/cli/v2"
+)
+
+// Flag for the unix socket path, shared by all the commands that use the socket
+var socketFlag = &cli.StringFlag{
+	Name:    "socket",
+	Usage:   "Path of the unix socket used to control the server, use different paths to run several instances",
+	Value:   utils.DEFAULT_SOCKET_PATH,
+	EnvVars: []string{utils.SOCKET_ENV_NAME},
+}
+
+// Sends a command to the running server over the unix socket and decodes the data of each response frame using onData
+// Error responses from the server are returned as errors
+func callSocketCommand(socketPath, command string, args any, timeout time.Duration, onData func(data json.RawMessage) error) error {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("server not running")
+	}
+
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	return protocol.NewConn(conn).Call(command, args, onData)
+}
+
+// Sends a command that responds with a single string
+func callSocketStringCommand(socketPath, command string, args any, timeout time.Duration) (string, error) {
+	var response string
+
+	err := callSocketCommand(socketPath, command, args, timeout, func(data json.RawMessage) error {
+		return json.Unmarshal(data, &response)
+	})
+
+	return response, err
+}
@@ -3,8 +3,11 @@ package commands
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"sync"
 
 	"github.com/AmrSaber/redirector/src/config"
@@ -14,32 +17,37 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-const URL_ENV_NAME = "CONFIG_URL"
-
 var StartCommand = &cli.Command{
 	Name:  "start",
 	Usage: "Start redirector server",
-	Flags: []cli.Flag{
-		&cli.StringFlag{
-			Name:  "file",
-			Usage: "YAML file containing configuration",
+	Flags: append(slices.Clone(configSourceFlags),
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only read config and print results, don't start server",
 		},
 		&cli.StringFlag{
-			Name:  "url",
-			Usage: "URL containing configuration yaml file",
+			Name:  "log-level",
+			Usage: "Minimum level of logs, one of (debug, info, warn, error). Overwrites log-level in config",
 		},
-		&cli.BoolFlag{
-			Name:  "stdin",
-			Usage: "Read configuration from stdin",
+		socketFlag,
+		&cli.StringFlag{
+			Name:  "socket-mode",
+			Usage: "File mode of the unix socket in octal, e.g. 0660",
 		},
-		&cli.BoolFlag{
-			Name:  "dry-run",
-			Usage: "Only read config and print results, don't start server",
+		&cli.StringFlag{
+			Name:  "socket-owner",
+			Usage: `Owner of the unix socket in the form of "user", "user:group" or ":group"`,
 		},
-	},
+	),
 	Action: func(c *cli.Context) error {
+		if logLevel := c.String("log-level"); logLevel != "" {
+			if err := logger.SetFlagLevel(logLevel); err != nil {
+				return err
+			}
+		}
+
 		if appVersion := utils.GetVersion(); appVersion != "" {
-			logger.Std.Printf("Starting redirector %s\n", utils.GetVersion())
+			logger.Infof("Starting redirector %s", utils.GetVersion())
 		}
 
 		// Runtime context
@@ -47,18 +55,39 @@ var StartCommand = &cli.Command{
 		defer cancel()
 
 		// Get command flags
-		url := c.String("url")
-		filePath := c.String("file")
-		readStdin := c.Bool("stdin")
+		readStdin, filePath, url := getConfigSource(c)
 		dryRun := c.Bool("dry-run")
 
-		// given flag overwrites env variable
-		if urlEnvValue := os.Getenv(URL_ENV_NAME); urlEnvValue != "" {
-			if url == "" {
-				url = urlEnvValue
-			} else {
-				logger.Std.Printf("Effect of env variable %s overwritten by provided url flag", URL_ENV_NAME)
+		socketOptions := servers.SocketOptions{
+			Path:  c.String("socket"),
+			Owner: c.String("socket-owner"),
+		}
+
+		if socketMode := c.String("socket-mode"); socketMode != "" {
+			mode, err := strconv.ParseUint(socketMode, 8, 32)
+			if err != nil {
+				return fmt.Errorf("invalid socket mode %q: %w", socketMode, err)
+			}
+
+			socketOptions.Mode = os.FileMode(mode)
+		}
+
+		// Dry runs only check the config, so its global options are not applied
+		if dryRun {
+			configManager, err := config.CheckConfigSource(readStdin, filePath, url)
+			if err != nil {
+				return fmt.Errorf("could not load config: %w", err)
+			}
+
+			if configManager == nil {
+				logger.Std.Println("No configuration provided!")
+				return nil
 			}
+
+			defer configManager.Close()
+
+			logger.Std.Printf("Parsed configurations:\n\n%s\n", configManager.GetStringConfig())
+			return nil
 		}
 
 		configManager := config.CreateConfigManager(ctx, readStdin, filePath, url)
@@ -69,11 +98,7 @@ var StartCommand = &cli.Command{
 
 		defer configManager.Close()
 
-		logger.Std.Printf("Parsed configurations:\n\n%s\n", configManager.GetStringConfig())
-
-		if dryRun {
-			return nil
-		}
+		logger.Debugf("Parsed configurations:\n\n%s", configManager.GetStringConfig())
 
 		// Listen for interrupts to cancel context
 		go func() {
@@ -84,32 +109,37 @@ var StartCommand = &cli.Command{
 			cancel()
 		}()
 
-		httpDoneChan := servers.StartHttpServer(ctx, configManager)
-		socketDoneChan := servers.StartUnixSocketListener(ctx)
-
-		errs := make([]error, 0, 2)
-		var wg sync.WaitGroup
-		wg.Add(2)
+		doneChans := []<-chan error{
+			servers.StartHttpServer(ctx, configManager),
+			servers.StartUnixSocketListener(ctx, configManager, socketOptions),
+		}
 
-		go func() {
-			defer wg.Done()
+		if configManager.GetTlsPort() != 0 {
+			doneChans = append(doneChans, servers.StartHttpsServer(ctx, configManager))
+		}
 
-			if err := <-httpDoneChan; err != nil {
-				errs = append(errs, err)
-			}
+		if configManager.GetMetricsOptions() != nil {
+			doneChans = append(doneChans, servers.StartMetricsServer(ctx, configManager))
+		}
 
-			cancel()
-		}()
+		errs := make([]error, 0, len(doneChans))
+		var errsLock sync.Mutex
+		var wg sync.WaitGroup
+		wg.Add(len(doneChans))
 
-		go func() {
-			defer wg.Done()
+		for _, doneChan := range doneChans {
+			go func() {
+				defer wg.Done()
 
-			if err := <-socketDoneChan; err != nil {
-				errs = append(errs, err)
-			}
+				if err := <-doneChan; err != nil {
+					errsLock.Lock()
+					errs = append(errs, err)
+					errsLock.Unlock()
+				}
 
-			cancel()
-		}()
+				cancel()
+			}()
+		}
 
 		wg.Wait()
 
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/protocol"
+	"github.com/AmrSaber/redirector/src/models"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+)
+
+var StatusCommand = &cli.Command{
+	Name:  "status",
+	Usage: "prints the status of the running server",
+	Flags: []cli.Flag{socketFlag},
+	Action: func(c *cli.Context) error {
+		logger.ResetLoggersFlags()
+
+		var status models.Status
+		err := callSocketCommand(c.String("socket"), protocol.COMMAND_STATUS, nil, 2*time.Second, func(data json.RawMessage) error {
+			return json.Unmarshal(data, &status)
+		})
+		if err != nil {
+			return err
+		}
+
+		out, _ := yaml.Marshal(status)
+		logger.Std.Print(string(out))
+
+		return nil
+	},
+}
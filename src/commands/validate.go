@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/AmrSaber/redirector/src/config"
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/models"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	FORMAT_TEXT   = "text"
+	FORMAT_JSON   = "json"
+	FORMAT_GITHUB = "github"
+)
+
+var ValidateCommand = &cli.Command{
+	Name:   "validate",
+	Usage:  "validates the configuration without starting the server, exits with non-zero code if it's invalid",
+	Flags:  validationFlags,
+	Action: validationAction(false),
+}
+
+var LintCommand = &cli.Command{
+	Name:   "lint",
+	Usage:  "validates the configuration and looks for rules that have no effect, exits with non-zero code if any problem is found, including warnings",
+	Flags:  validationFlags,
+	Action: validationAction(true),
+}
+
+var validationFlags = append(slices.Clone(configSourceFlags),
+	&cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"f"},
+		Usage:   fmt.Sprintf("output format, one of (%s, %s, %s)", FORMAT_TEXT, FORMAT_JSON, FORMAT_GITHUB),
+		Value:   FORMAT_TEXT,
+	},
+)
+
+// Validates the config and prints the diagnostics, the command fails on errors, and on warnings too if failOnWarnings is set
+func validationAction(failOnWarnings bool) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		logger.ResetLoggersFlags()
+
+		format := c.String("format")
+		if format != FORMAT_TEXT && format != FORMAT_JSON && format != FORMAT_GITHUB {
+			return fmt.Errorf("invalid format %q", format)
+		}
+
+		source, uri, name := getConfigSourceURI(c)
+		if source == "" {
+			return fmt.Errorf("no configuration provided")
+		}
+
+		yamlBody, err := config.ReadConfig(source, uri)
+		if err != nil {
+			return fmt.Errorf("could not read config: %w", err)
+		}
+
+		diagnostics := config.ValidateConfig(yamlBody)
+		logger.Std.Print(formatDiagnostics(diagnostics, format, name))
+
+		if diagnostics.HasErrors() || (failOnWarnings && len(diagnostics) > 0) {
+			return cli.Exit("", 1)
+		}
+
+		return nil
+	}
+}
+
+func formatDiagnostics(diagnostics models.Diagnostics, format, name string) string {
+	var builder strings.Builder
+
+	switch format {
+	case FORMAT_JSON:
+		if diagnostics == nil {
+			diagnostics = models.Diagnostics{}
+		}
+
+		out, _ := json.MarshalIndent(struct {
+			Source      string             `json:"source"`
+			Valid       bool               `json:"valid"`
+			Diagnostics models.Diagnostics `json:"diagnostics"`
+		}{name, !diagnostics.HasErrors(), diagnostics}, "", "  ")
+
+		builder.Write(out)
+		builder.WriteByte('\n')
+
+	case FORMAT_GITHUB:
+		for _, diagnostic := range diagnostics {
+			// Annotations are only attached to files, the position is meaningless for other sources
+			properties := []string{}
+			if name != "stdin" && !strings.Contains(name, "://") {
+				properties = append(properties, "file="+escapeGithubProperty(name))
+
+				if diagnostic.Line != 0 {
+					properties = append(properties, fmt.Sprintf("line=%d", diagnostic.Line))
+				}
+
+				if diagnostic.Column != 0 {
+					properties = append(properties, fmt.Sprintf("col=%d", diagnostic.Column))
+				}
+			}
+			properties = append(properties, "title="+escapeGithubProperty(diagnostic.Code))
+
+			fmt.Fprintf(&builder, "::%s %s::%s\n", diagnostic.Severity, strings.Join(properties, ","), escapeGithubData(diagnostic.Message))
+		}
+
+	default:
+		for _, diagnostic := range diagnostics {
+			position := name
+			if diagnostic.Line != 0 {
+				position += fmt.Sprintf(":%d", diagnostic.Line)
+			}
+
+			if diagnostic.Column != 0 {
+				position += fmt.Sprintf(":%d", diagnostic.Column)
+			}
+
+			fmt.Fprintf(&builder, "%s: %s [%s] %s\n", position, diagnostic.Severity, diagnostic.Code, diagnostic.Message)
+		}
+
+		if len(diagnostics) == 0 {
+			fmt.Fprintf(&builder, "%s: configuration is valid\n", name)
+		} else if !diagnostics.HasErrors() {
+			fmt.Fprintf(&builder, "%s: configuration is valid, with %d warning(s)\n", name, len(diagnostics))
+		}
+	}
+
+	return builder.String()
+}
+
+// Escapes the message of a GitHub workflow command
+func escapeGithubData(value string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(value)
+}
+
+// Escapes a property of a GitHub workflow command
+func escapeGithubProperty(value string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(value)
+}
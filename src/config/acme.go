@@ -0,0 +1,83 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/AmrSaber/redirector/src/models"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Creates an ACME certificates manager that issues certificates for the hosts allowed by the given policy
+func newAcmeManager(options *models.AcmeOptions, hostPolicy autocert.HostPolicy) (*autocert.Manager, error) {
+	client := &acme.Client{DirectoryURL: options.DirectoryURL}
+
+	if options.DirectoryCA != "" {
+		caPem, err := os.ReadFile(options.DirectoryCA)
+		if err != nil {
+			return nil, fmt.Errorf("could not read ACME directory CA: %w", err)
+		}
+
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificates found in ACME directory CA %q", options.DirectoryCA)
+		}
+
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: rootCAs}},
+		}
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(options.CacheDir),
+		HostPolicy: hostPolicy,
+		Email:      options.Email,
+		Client:     client,
+	}, nil
+}
+
+// Returns the hosts that certificates can be issued for: all the "from" domains without wildcards
+func getAcmeDomains(config models.Config) []string {
+	domains := make([]string, 0, len(config.Redirects))
+
+	for _, r := range config.Redirects {
+		if r.From == "" || strings.Contains(r.From, "*") || slices.Contains(domains, r.From) {
+			continue
+		}
+
+		domains = append(domains, r.From)
+	}
+
+	return domains
+}
+
+// Allows only the current ACME domains, so domains added or removed on reload are picked up
+func (manager *ConfigManager) acmeHostPolicy(_ context.Context, host string) error {
+	if !slices.Contains(manager.snapshot.Load().acmeDomains, host) {
+		return fmt.Errorf("host %q is not configured for ACME", host)
+	}
+
+	return nil
+}
+
+// Returns a handler that answers ACME HTTP-01 challenges, and passes other requests to the fallback
+func (manager *ConfigManager) HandleAcmeChallenge(fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		acmeManager := manager.snapshot.Load().acme
+
+		if acmeManager == nil {
+			fallback.ServeHTTP(res, req)
+			return
+		}
+
+		acmeManager.HTTPHandler(fallback).ServeHTTP(res, req)
+	})
+}
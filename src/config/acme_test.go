@@ -0,0 +1,453 @@
+package config
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/models"
+	"golang.org/x/crypto/acme"
+)
+
+func TestAcmeDomains(t *testing.T) {
+	config := models.Config{
+		Redirects: []models.Redirect{
+			{From: "a.example.com"},
+			{From: "a.example.com", Path: "/docs"},
+			{From: "*.example.com"},
+			{From: "**.example.org"},
+			{FromRegex: `b\.example\.com/.*`},
+			{From: "b.example.com"},
+		},
+	}
+
+	got := getAcmeDomains(config)
+	expected := []string{"a.example.com", "b.example.com"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestAcmeHostPolicyReload(t *testing.T) {
+	dir := t.TempDir()
+	configPath := path.Join(dir, "config.yaml")
+
+	writeConfig := func(from string) {
+		os.WriteFile(configPath, []byte(`
+tls:
+  acme:
+    cache-dir: `+path.Join(dir, "cache")+`
+redirects:
+  - from: `+from+`
+    to: https://target.com
+`), 0o600)
+	}
+
+	writeConfig("a.example.com")
+
+	manager := NewConfigManager(models.SOURCE_FILE, configPath)
+	defer manager.Close()
+
+	if err := manager.LoadConfig(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	acmeManager := manager.snapshot.Load().acme
+	if acmeManager == nil {
+		t.Fatalf("expected ACME manager to be created")
+	}
+
+	if err := manager.acmeHostPolicy(context.Background(), "a.example.com"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// Test domain removed on reload
+	writeConfig("b.example.com")
+	if err := manager.LoadConfig(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := manager.acmeHostPolicy(context.Background(), "a.example.com"); err == nil {
+		t.Errorf("expected error for removed domain, got nil")
+	}
+
+	if err := manager.acmeHostPolicy(context.Background(), "b.example.com"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// Same ACME options keep the same manager
+	if manager.snapshot.Load().acme != acmeManager {
+		t.Errorf("expected ACME manager to be kept")
+	}
+}
+
+func TestAcmeIssueCertificate(t *testing.T) {
+	dir := t.TempDir()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The test CA validates TLS-ALPN-01 challenges against the listener, as a real CA would
+	acmeServer := newTestAcmeServer(t, func(domain string) error {
+		conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+			ServerName:         domain,
+			NextProtos:         []string{acme.ALPNProto},
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		leaf := conn.ConnectionState().PeerCertificates[0]
+		isChallengeCert := slices.ContainsFunc(leaf.Extensions, func(extension pkix.Extension) bool {
+			return extension.Id.Equal(acmeIdentifierOID)
+		})
+
+		if !isChallengeCert || leaf.VerifyHostname(domain) != nil {
+			return fmt.Errorf("invalid challenge certificate for %q", domain)
+		}
+
+		return nil
+	})
+
+	caPath := path.Join(dir, "ca.pem")
+	os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: acmeServer.Certificate().Raw}), 0o600)
+
+	configPath := path.Join(dir, "config.yaml")
+	os.WriteFile(configPath, []byte(`
+tls:
+  acme:
+    directory-url: `+acmeServer.URL+`/directory
+    directory-ca: `+caPath+`
+    cache-dir: `+path.Join(dir, "cache")+`
+redirects:
+  - from: a.example.com
+    to: https://target.com
+`), 0o600)
+
+	manager := NewConfigManager(models.SOURCE_FILE, configPath)
+	defer manager.Close()
+
+	if err := manager.LoadConfig(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tlsListener := tls.NewListener(listener, &tls.Config{
+		GetCertificate: manager.GetCertificate,
+		NextProtos:     []string{"http/1.1", acme.ALPNProto},
+	})
+	defer tlsListener.Close()
+
+	go func() {
+		for {
+			conn, err := tlsListener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				_ = conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(acmeServer.ca)
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{ServerName: "a.example.com", RootCAs: rootCAs})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	conn.Close()
+
+	// Hosts not in the config are refused
+	conn, err = tls.Dial("tcp", listener.Addr().String(), &tls.Config{ServerName: "b.example.com", RootCAs: rootCAs})
+	if err == nil {
+		conn.Close()
+		t.Errorf("expected error for unknown host, got nil")
+	}
+
+	if issued := acmeServer.issuedCount(); issued != 1 {
+		t.Errorf("expected 1 issued certificate, got %d", issued)
+	}
+}
+
+// Extension that marks TLS-ALPN-01 challenge certificates
+var acmeIdentifierOID = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+// Minimal in-process ACME directory that issues certificates for single domain orders
+type testAcmeServer struct {
+	*httptest.Server
+
+	ca    *x509.Certificate
+	caKey *ecdsa.PrivateKey
+
+	// Validates the challenge of the given domain
+	validate func(domain string) error
+
+	mutex  sync.Mutex
+	nonce  int
+	orders []*testAcmeOrder
+}
+
+type testAcmeOrder struct {
+	domain string
+	status string
+	cert   []byte
+}
+
+func newTestAcmeServer(t *testing.T, validate func(domain string) error) *testAcmeServer {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDer, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ca, _ := x509.ParseCertificate(caDer)
+	server := &testAcmeServer{ca: ca, caKey: caKey, validate: validate}
+
+	server.Server = httptest.NewTLSServer(http.HandlerFunc(server.handle))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (server *testAcmeServer) issuedCount() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	count := 0
+	for _, order := range server.orders {
+		if order.cert != nil {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (server *testAcmeServer) handle(res http.ResponseWriter, req *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.nonce++
+	res.Header().Set("Replay-Nonce", strconv.Itoa(server.nonce))
+
+	base := "https://" + req.Host
+	resource, rawId, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+
+	if resource == "directory" {
+		writeAcmeJSON(res, http.StatusOK, "", map[string]string{
+			"newNonce":   base + "/nonce",
+			"newAccount": base + "/account",
+			"newOrder":   base + "/new-order",
+		})
+		return
+	}
+
+	if resource == "nonce" {
+		return
+	}
+
+	var payload struct {
+		Identifiers []struct{ Value string }
+		CSR         string
+	}
+
+	if err := readAcmePayload(req, &payload); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch resource {
+	case "account":
+		writeAcmeJSON(res, http.StatusCreated, base+"/account/1", map[string]string{"status": acme.StatusValid})
+		return
+
+	case "new-order":
+		if len(payload.Identifiers) != 1 {
+			http.Error(res, "expected one identifier", http.StatusBadRequest)
+			return
+		}
+
+		server.orders = append(server.orders, &testAcmeOrder{domain: payload.Identifiers[0].Value, status: acme.StatusPending})
+		rawId = strconv.Itoa(len(server.orders) - 1)
+		writeAcmeJSON(res, http.StatusCreated, base+"/order/"+rawId, server.orders[len(server.orders)-1].toJSON(base, rawId))
+		return
+	}
+
+	id, err := strconv.Atoi(rawId)
+	if err != nil || id < 0 || id >= len(server.orders) {
+		http.NotFound(res, req)
+		return
+	}
+
+	order := server.orders[id]
+
+	switch resource {
+	case "order":
+		writeAcmeJSON(res, http.StatusOK, base+"/order/"+rawId, order.toJSON(base, rawId))
+
+	case "authz":
+		authzStatus := acme.StatusPending
+		if order.status != acme.StatusPending {
+			authzStatus = acme.StatusValid
+		}
+
+		writeAcmeJSON(res, http.StatusOK, "", map[string]any{
+			"status":     authzStatus,
+			"identifier": map[string]string{"type": "dns", "value": order.domain},
+			"challenges": []map[string]string{
+				{"type": "tls-alpn-01", "url": base + "/challenge/" + rawId, "token": "token-" + rawId, "status": authzStatus},
+			},
+		})
+
+	case "challenge":
+		if err := server.validate(order.domain); err != nil {
+			order.status = acme.StatusInvalid
+		} else if order.status == acme.StatusPending {
+			order.status = acme.StatusReady
+		}
+
+		writeAcmeJSON(res, http.StatusOK, "", map[string]string{"type": "tls-alpn-01", "url": base + "/challenge/" + rawId, "status": order.status})
+
+	case "finalize":
+		cert, err := server.issue(order.domain, payload.CSR, int64(id))
+		if order.status != acme.StatusReady || err != nil {
+			http.Error(res, fmt.Sprintf("could not finalize order: %v", err), http.StatusForbidden)
+			return
+		}
+
+		order.status, order.cert = acme.StatusValid, cert
+		writeAcmeJSON(res, http.StatusOK, base+"/order/"+rawId, order.toJSON(base, rawId))
+
+	case "cert":
+		if order.cert == nil {
+			http.NotFound(res, req)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/pem-certificate-chain")
+		pem.Encode(res, &pem.Block{Type: "CERTIFICATE", Bytes: order.cert})
+		pem.Encode(res, &pem.Block{Type: "CERTIFICATE", Bytes: server.ca.Raw})
+
+	default:
+		http.NotFound(res, req)
+	}
+}
+
+// Signs a certificate for the CSR if it only requests the order domain
+func (server *testAcmeServer) issue(domain string, encodedCsr string, serial int64) ([]byte, error) {
+	csrDer, err := base64.RawURLEncoding.DecodeString(encodedCsr)
+	if err != nil {
+		return nil, err
+	}
+
+	csr, err := x509.ParseCertificateRequest(csrDer)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Equal(csr.DNSNames, []string{domain}) {
+		return nil, fmt.Errorf("unexpected CSR names %v", csr.DNSNames)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial + 2),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	return x509.CreateCertificate(rand.Reader, template, server.ca, csr.PublicKey, server.caKey)
+}
+
+func (order *testAcmeOrder) toJSON(base string, id string) map[string]any {
+	result := map[string]any{
+		"status":         order.status,
+		"identifiers":    []map[string]string{{"type": "dns", "value": order.domain}},
+		"authorizations": []string{base + "/authz/" + id},
+		"finalize":       base + "/finalize/" + id,
+	}
+
+	if order.cert != nil {
+		result["certificate"] = base + "/cert/" + id
+	}
+
+	return result
+}
+
+// Reads the payload of a JWS request body into target, signatures are not checked
+func readAcmePayload(req *http.Request, target any) error {
+	var body struct {
+		Payload string `json:"payload"`
+	}
+
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return err
+	}
+
+	// POST-as-GET requests have an empty payload
+	if body.Payload == "" {
+		return nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(body.Payload)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(payload, target)
+}
+
+func writeAcmeJSON(res http.ResponseWriter, status int, location string, body any) {
+	if location != "" {
+		res.Header().Set("Location", location)
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(body)
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/AmrSaber/redirector/src/models"
+)
+
+type certificateEntry struct {
+	domain      string
+	certificate *tls.Certificate
+}
+
+// Loads all the certificates defined in TLS options, mapped to the domains they serve
+func loadCertificates(options *models.TlsOptions) ([]certificateEntry, error) {
+	if options == nil {
+		return nil, nil
+	}
+
+	certificates := make([]certificateEntry, 0)
+
+	for _, certConfig := range options.Certificates {
+		entries, err := loadCertificate(certConfig.Cert, certConfig.Key, certConfig.Domains)
+		if err != nil {
+			return nil, err
+		}
+
+		certificates = append(certificates, entries...)
+	}
+
+	if options.CertificatesDir != "" {
+		files, err := os.ReadDir(options.CertificatesDir)
+		if err != nil {
+			return nil, fmt.Errorf("could not read certificates directory: %w", err)
+		}
+
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), ".crt") {
+				continue
+			}
+
+			certPath := path.Join(options.CertificatesDir, file.Name())
+			keyPath := strings.TrimSuffix(certPath, ".crt") + ".key"
+
+			entries, err := loadCertificate(certPath, keyPath, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			certificates = append(certificates, entries...)
+		}
+	}
+
+	return certificates, nil
+}
+
+// Loads a certificate and key pair, if no domains are given, the domains of the certificate are used
+func loadCertificate(certPath, keyPath string, domains []string) ([]certificateEntry, error) {
+	certificate, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load certificate %q: %w", certPath, err)
+	}
+
+	if len(domains) == 0 {
+		leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse certificate %q: %w", certPath, err)
+		}
+
+		domains = leaf.DNSNames
+	}
+
+	entries := make([]certificateEntry, 0, len(domains))
+	for _, domain := range domains {
+		entries = append(entries, certificateEntry{domain: domain, certificate: &certificate})
+	}
+
+	return entries, nil
+}
+
+// Returns the certificate matching the given server name, or nil if none matched
+func matchCertificate(serverName string, certificates *domainIndex[certificateEntry]) *tls.Certificate {
+	matched := certificates.match(serverName)
+	if matched == nil {
+		return nil
+	}
+
+	return matched.certificate
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/models"
+)
+
+// Writes a self-signed certificate for the given domains to dir/name.crt and dir/name.key
+func writeTestCertificate(t *testing.T, dir, name string, domains ...string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     domains,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	if err := os.WriteFile(path.Join(dir, name+".crt"), certPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path.Join(dir, name+".key"), keyPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertificateMatching(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCertificate(t, dir, "exact", "exact.example.com")
+	writeTestCertificate(t, dir, "wildcard", "*.example.com")
+
+	explicitDir := t.TempDir()
+	writeTestCertificate(t, explicitDir, "explicit", "ignored.com")
+
+	certificates, err := loadCertificates(&models.TlsOptions{
+		CertificatesDir: dir,
+		Certificates: []models.TlsCertificate{
+			{
+				Domains: []string{"**.other.com"},
+				Cert:    path.Join(explicitDir, "explicit.crt"),
+				Key:     path.Join(explicitDir, "explicit.key"),
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	getCertDomain := func(serverName string) string {
+		cert := matchCertificate(serverName, newDomainIndex(certificates, func(entry certificateEntry) string { return entry.domain }))
+		if cert == nil {
+			return ""
+		}
+
+		leaf, _ := x509.ParseCertificate(cert.Certificate[0])
+		return leaf.DNSNames[0]
+	}
+
+	testCases := map[string]string{
+		"exact.example.com": "exact.example.com",
+		"a.example.com":     "*.example.com",
+		"a.b.other.com":     "ignored.com",
+		"unknown.com":       "",
+	}
+
+	for serverName, expected := range testCases {
+		if got := getCertDomain(serverName); got != expected {
+			t.Errorf("%s: expected certificate for %q, got %q", serverName, expected, got)
+		}
+	}
+
+	// Test missing key
+	os.Remove(path.Join(dir, "exact.key"))
+	if _, err := loadCertificates(&models.TlsOptions{CertificatesDir: dir}); err == nil {
+		t.Errorf("expected error on missing key, got nil")
+	}
+}
+
+func TestCertificatesReload(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCertificate(t, dir, "cert", "a.example.com")
+
+	configPath := path.Join(dir, "config.yaml")
+	os.WriteFile(configPath, []byte("tls:\n  certificates-dir: "+dir+"\nredirects: []\n"), 0o600)
+
+	manager := NewConfigManager(models.SOURCE_FILE, configPath)
+	defer manager.Close()
+
+	if err := manager.LoadConfig(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	hello := &tls.ClientHelloInfo{ServerName: "a.example.com"}
+	before, _ := manager.GetCertificate(hello)
+
+	writeTestCertificate(t, dir, "cert", "a.example.com")
+	if err := manager.LoadConfig(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	after, _ := manager.GetCertificate(hello)
+	if before == nil || after == nil || before == after {
+		t.Errorf("expected certificate to be reloaded")
+	}
+
+	// Test invalid certificates keep the previous ones
+	os.WriteFile(path.Join(dir, "cert.crt"), []byte("invalid"), 0o600)
+	if err := manager.LoadConfig(); err == nil {
+		t.Errorf("expected error on invalid certificate, got nil")
+	}
+
+	if kept, _ := manager.GetCertificate(hello); kept != after {
+		t.Errorf("expected previous certificate to be kept")
+	}
+
+	// Test first certificate is the default
+	if fallback, _ := manager.GetCertificate(&tls.ClientHelloInfo{ServerName: "unknown.com"}); fallback != after {
+		t.Errorf("expected first certificate as default")
+	}
+}
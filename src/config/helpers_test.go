@@ -1,6 +1,20 @@
 package config
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/AmrSaber/redirector/src/models"
+)
+
+// Returns a pointer to the element of the list that matched the domain after mapping it with the given mapper
+func matchDomain[T any](domain string, list []T, mapper func(T) string) *T {
+	return newDomainIndex(list, mapper).match(domain)
+}
+
+// Returns a pointer to the redirect that best matches the given host and path
+func matchRedirect(host, requestPath string, redirects []models.Redirect) *models.Redirect {
+	return newRedirectMatcher(redirects).match(host, requestPath)
+}
 
 func TestConfigDomainMatching(t *testing.T) {
 	type Domain string
@@ -72,3 +86,110 @@ func TestConfigDomainMatching(t *testing.T) {
 		t.Errorf("expected %s, got %s", list[1], *exact)
 	}
 }
+
+func TestRedirectPathMatching(t *testing.T) {
+	redirects := []models.Redirect{
+		{From: "go.example.com", To: "https://fallback.com"},
+		{From: "go.example.com", Path: "/docs", PathMatch: models.PATH_MATCH_PREFIX, To: "https://docs.com"},
+		{From: "go.example.com", Path: "/docs/api", PathMatch: models.PATH_MATCH_PREFIX, To: "https://api.com"},
+		{From: "go.example.com", Path: "/docs/api/v1", PathMatch: models.PATH_MATCH_EXACT, To: "https://v1.com"},
+		{From: "go.example.com", Path: "/blog/*/comments", PathMatch: models.PATH_MATCH_GLOB, To: "https://comments.com"},
+		{From: "*.example.com", Path: "/blog", To: "https://blog.com"},
+	}
+
+	testCases := []struct {
+		host, path string
+		expected   *models.Redirect
+	}{
+		{"go.example.com", "/", &redirects[0]},
+		{"go.example.com", "/docsx", &redirects[0]},
+		{"go.example.com", "/docs", &redirects[1]},
+		{"go.example.com", "/docs/intro", &redirects[1]},
+		{"go.example.com", "/docs/api/v2", &redirects[2]},
+		{"go.example.com", "/docs/api/v1", &redirects[3]},
+		{"go.example.com:8080", "/docs/api/v1/", &redirects[3]},
+		{"go.example.com", "/blog/post-1/comments", &redirects[4]},
+		{"go.example.com", "/blog/post-1", &redirects[0]},
+		{"other.example.com", "/blog/post-1", &redirects[5]},
+		{"other.example.com", "/docs", nil},
+	}
+
+	for _, testCase := range testCases {
+		got := matchRedirect(testCase.host, testCase.path, redirects)
+		if got != testCase.expected {
+			t.Errorf("%s%s: expected %v, got %v", testCase.host, testCase.path, testCase.expected, got)
+		}
+	}
+
+	// Test first rule wins on equal specificity
+	redirects = []models.Redirect{
+		{From: "*.example.com", Path: "/a", To: "https://first.com"},
+		{From: "*.example.com", Path: "/a", To: "https://second.com"},
+	}
+
+	if got := matchRedirect("x.example.com", "/a", redirects); got != &redirects[0] {
+		t.Errorf("expected first redirect, got %v", got)
+	}
+}
+
+func TestRegexRedirectMatching(t *testing.T) {
+	redirects := []models.Redirect{
+		{From: "legacy.example.com", Path: "/about", To: "https://about.com"},
+		{FromRegex: `legacy\.example\.com/items/(\d+)`, To: "https://new.com/products/$1"},
+		{FromRegex: `legacy\.example\.com/.*`, To: "https://new.com"},
+	}
+
+	testCases := []struct {
+		host, path string
+		expected   *models.Redirect
+	}{
+		{"legacy.example.com", "/about", &redirects[0]},
+		{"legacy.example.com:8080", "/items/12", &redirects[1]},
+		{"legacy.example.com", "/items/abc", &redirects[2]},
+		{"other.example.com", "/items/12", nil},
+	}
+
+	for _, testCase := range testCases {
+		got := matchRedirect(testCase.host, testCase.path, redirects)
+		if got != testCase.expected {
+			t.Errorf("%s%s: expected %v, got %v", testCase.host, testCase.path, testCase.expected, got)
+		}
+	}
+}
+
+func TestMultiLabelWildcardMatching(t *testing.T) {
+	type Domain string
+	mapper := func(d Domain) string { return string(d) }
+
+	list := []Domain{"**.example.com"}
+
+	for _, domain := range []string{"a.example.com", "a.b.example.com", "a.b.c.example.com:8080"} {
+		if matchDomain(domain, list, mapper) == nil {
+			t.Errorf("%s: expected redirect, got nil", domain)
+		}
+	}
+
+	for _, domain := range []string{"example.com", "a.example.org"} {
+		if matchDomain(domain, list, mapper) != nil {
+			t.Errorf("%s: expected nil, got redirect", domain)
+		}
+	}
+
+	// Test "**" in the middle
+	list = []Domain{"api.**.*.com"}
+
+	if matchDomain("api.a.b.example.com", list, mapper) == nil {
+		t.Errorf("expected redirect, got nil")
+	}
+
+	if matchDomain("api.example.com", list, mapper) != nil {
+		t.Errorf("expected nil, got redirect")
+	}
+
+	// Test exact match still takes precedence
+	list = []Domain{"**.example.com", "a.b.example.com"}
+
+	if exact := matchDomain("a.b.example.com", list, mapper); exact != &list[1] {
+		t.Errorf("expected %s, got %v", list[1], exact)
+	}
+}
@@ -29,20 +29,19 @@ func CreateConfigManager(ctx context.Context, readStdin bool, filePath, url stri
 		// Watch config file for updates
 		updatesChan, err := watchers.WatchConfigFile(ctx, filePath)
 		if err != nil {
-			logger.Err.Println("Could not watch config file: ", err)
+			logger.Warnf("Could not watch config file: %s", err)
 			return manager
 		}
 
 		// Update config on file change
 		go func() {
 			for range updatesChan {
+				// Last valid config is kept if the new one is invalid
 				if err := manager.LoadConfig(); err != nil {
-					logger.Err.Fatal("Config file changed, could not load new config: ", err)
+					logger.Errorf("Config file changed, could not load new config, keeping last valid config: %s", err)
 				} else {
-					logger.Std.Printf(
-						"Config file changed; config reloaded. New config:\n\n%s\n",
-						manager.GetStringConfig(),
-					)
+					logger.Infof("Config file changed; config reloaded")
+					logger.Debugf("New config:\n\n%s", manager.GetStringConfig())
 				}
 			}
 		}()
@@ -62,3 +61,27 @@ func CreateConfigManager(ctx context.Context, readStdin bool, filePath, url stri
 
 	return nil
 }
+
+// Creates a config manager for the first given source and checks its config, without watching it or applying its global options
+// Returns nil if no source is given
+func CheckConfigSource(readStdin bool, filePath, url string) (*ConfigManager, error) {
+	var manager *ConfigManager
+
+	switch {
+	case readStdin:
+		manager = NewConfigManager(models.SOURCE_STDIN, "")
+	case filePath != "":
+		manager = NewConfigManager(models.SOURCE_FILE, filePath)
+	case url != "":
+		manager = NewConfigManager(models.SOURCE_URL, url)
+	default:
+		return nil, nil
+	}
+
+	if err := manager.CheckConfig(); err != nil {
+		manager.Close()
+		return nil, err
+	}
+
+	return manager, nil
+}
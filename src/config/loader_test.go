@@ -0,0 +1,64 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestWatchedConfigInvalidReload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(configPath, []byte("redirects:\n  - from: a.com\n    to: http://b.com\n"), 0o600)
+
+	manager := CreateConfigManager(ctx, false, configPath, "")
+	defer manager.Close()
+
+	waitFor := func(condition func() bool) bool {
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); {
+			if condition() {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		return false
+	}
+
+	// Test invalid config keeps the last valid one and records the error
+	os.WriteFile(configPath, []byte("redirects:\n  - from: a.com\n"), 0o600)
+
+	hasError := waitFor(func() bool {
+		return manager.GetLoadStatus().LastError != nil
+	})
+	if !hasError {
+		t.Fatalf("expected load error to be recorded")
+	}
+
+	if redirect := manager.GetRedirect("a.com", "/"); redirect == nil || redirect.To != "http://b.com" {
+		t.Errorf("expected last valid config to be kept, got %+v", redirect)
+	}
+
+	if loadStatus := manager.GetLoadStatus(); loadStatus.LastErrorAt.IsZero() || loadStatus.FailedLoads == 0 {
+		t.Errorf("expected error time and failed loads count, got %+v", loadStatus)
+	}
+
+	// Test a subsequent fix is picked up and clears the error
+	os.WriteFile(configPath, []byte("redirects:\n  - from: a.com\n    to: http://c.com\n"), 0o600)
+
+	reloaded := waitFor(func() bool {
+		redirect := manager.GetRedirect("a.com", "/")
+		return redirect != nil && redirect.To == "http://c.com"
+	})
+	if !reloaded {
+		t.Fatalf("expected fixed config to be loaded")
+	}
+
+	if err := manager.GetLoadStatus().LastError; err != nil {
+		t.Errorf("expected load error to be cleared, got %s", err)
+	}
+}
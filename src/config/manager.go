@@ -1,26 +1,80 @@
 package config
 
 import (
+	"crypto/tls"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"slices"
+	"sync/atomic"
+	"time"
 
 	"github.com/AmrSaber/redirector/src/lib/active"
 	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/metrics"
 	"github.com/AmrSaber/redirector/src/models"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
 )
 
+var configLoadsMetric = metrics.NewCounter(
+	"redirector_config_loads_total",
+	"Number of config loads and reloads by result",
+	"result",
+)
+
+// Immutable state of a loaded config, replaced as a whole on each load so that readers never wait for loads
+type configSnapshot struct {
+	config       *models.Config
+	certificates *domainIndex[certificateEntry]
+	acme         *autocert.Manager
+	acmeDomains  []string
+
+	redirects      *redirectMatcher
+	refreshDomains *domainIndex[models.RefreshDomain]
+
+	// Result of the last failed load, cleared on successful load
+	lastLoadError   error
+	lastLoadErrorAt time.Time
+	failedLoads     int
+}
+
+// Creates a snapshot of the given config, building the indexes used for lookups
+func newConfigSnapshot(config *models.Config, certificates []certificateEntry, acmeManager *autocert.Manager) *configSnapshot {
+	// Only set for URL configs
+	var refreshDomains []models.RefreshDomain
+	if config.UrlConfigRefresh != nil {
+		refreshDomains = config.UrlConfigRefresh.RefreshDomains
+	}
+
+	return &configSnapshot{
+		config:       config,
+		certificates: newDomainIndex(certificates, func(entry certificateEntry) string { return entry.domain }),
+		acme:         acmeManager,
+		acmeDomains:  getAcmeDomains(*config),
+
+		redirects:      newRedirectMatcher(config.Redirects),
+		refreshDomains: newDomainIndex(refreshDomains, func(d models.RefreshDomain) string { return d.Domain }),
+	}
+}
+
 type ConfigManager struct {
-	config models.Config
+	snapshot atomic.Pointer[configSnapshot]
+
+	// Serializes loads, readers use the current snapshot instead
 	active *active.ActiveObject
+
+	// Set while a background refresh is queued, so that concurrent requests do not queue one each
+	refreshing atomic.Bool
 }
 
 func NewConfigManager(source, uri string) *ConfigManager {
 	manager := &ConfigManager{
-		config: *models.NewConfig(source, uri),
 		active: active.NewActiveObject(1024),
 	}
 
+	manager.snapshot.Store(newConfigSnapshot(models.NewConfig(source, uri), nil, nil))
 	manager.active.Start()
 
 	return manager
@@ -37,122 +91,353 @@ func (manager *ConfigManager) LoadConfig() error {
 	)
 }
 
-// Gets the redirection that matches the given domain
-func (manager *ConfigManager) GetRedirect(domain string) *models.Redirect {
+// Loads the config only to check it: its log level and access log are not applied, as they are global
+func (manager *ConfigManager) CheckConfig() error {
 	return active.RunCommandSync(
 		manager.active,
-		func() *models.Redirect {
-			if manager.config.Source == models.SOURCE_URL {
-				if manager.config.IsStale() {
-					manager.loadConfigUnsafe()
-				}
-
-				if manager.config.UrlConfigRefresh.RemapAfterRefresh {
-					manager.refreshConfigUnsafe(domain)
-				} else {
-					manager.active.DispatchCommand(func() { manager.refreshConfigUnsafe(domain) })
-				}
+		func() error {
+			next, err := manager.readAndLoadConfigUnsafe(manager.snapshot.Load())
+			if err != nil {
+				return err
 			}
 
-			return manager.matchRedirect(domain)
+			manager.snapshot.Store(next)
+			return nil
 		},
 	)
 }
 
-func (manager *ConfigManager) matchRedirect(domain string) *models.Redirect {
-	return matchDomain(domain, manager.config.Redirects, func(r models.Redirect) string { return r.From })
+// Reloads the config from its source on demand
+func (manager *ConfigManager) ReloadConfig() error {
+	// Stdin was already consumed on start
+	if manager.snapshot.Load().config.Source == models.SOURCE_STDIN {
+		return fmt.Errorf("config loaded from stdin cannot be reloaded")
+	}
+
+	return manager.LoadConfig()
 }
 
-func (manager *ConfigManager) matchRefreshDomain(domain string) *models.RefreshDomain {
-	return matchDomain(
-		domain,
-		manager.config.UrlConfigRefresh.RefreshDomains,
-		func(d models.RefreshDomain) string { return d.Domain },
-	)
+// Returns a copy of the current config
+func (manager *ConfigManager) GetConfig() models.Config {
+	return *manager.snapshot.Load().config
 }
 
-func (manager *ConfigManager) refreshConfigUnsafe(domain string) {
-	if manager.config.Source != models.SOURCE_URL {
+// Gets the redirection that matches the given domain and path
+func (manager *ConfigManager) GetRedirect(domain, path string) *models.Redirect {
+	snapshot := manager.snapshot.Load()
+	redirect := snapshot.matchRedirect(domain, path)
+
+	if snapshot.config.Source != models.SOURCE_URL {
+		return redirect
+	}
+
+	// Stale config keeps being served until the new one is loaded
+	if snapshot.config.IsStale() {
+		manager.dispatchRefresh()
+	}
+
+	reason := snapshot.getRefreshReason(domain, redirect)
+	if reason == "" {
+		return redirect
+	}
+
+	logger.Infof("Refreshing config due to %s", reason)
+
+	if !snapshot.config.UrlConfigRefresh.RemapAfterRefresh {
+		manager.dispatchRefresh()
+		return redirect
+	}
+
+	// Only this request waits for the refresh, other requests keep using the current snapshot
+	if err := manager.LoadConfig(); err != nil {
+		logger.Errorf("Could not refresh config: %s", err)
+		return redirect
+	}
+
+	return manager.snapshot.Load().matchRedirect(domain, path)
+}
+
+// Queues a config refresh in the background, unless one is already queued
+func (manager *ConfigManager) dispatchRefresh() {
+	if !manager.refreshing.CompareAndSwap(false, true) {
 		return
 	}
 
-	matchedRefreshDomain := manager.matchRefreshDomain(domain)
-	matchedRedirect := manager.matchRedirect(domain)
+	manager.active.DispatchCommand(func() {
+		defer manager.refreshing.Store(false)
+
+		if err := manager.loadConfigUnsafe(); err != nil {
+			logger.Errorf("Could not refresh config: %s", err)
+		}
+	})
+}
+
+func (snapshot *configSnapshot) matchRedirect(domain, path string) *models.Redirect {
+	return snapshot.redirects.match(domain, path)
+}
+
+// Returns why the config should be refreshed after matching the given domain, or empty string if it should not
+func (snapshot *configSnapshot) getRefreshReason(domain string, matchedRedirect *models.Redirect) string {
+	matchedRefreshDomain := snapshot.refreshDomains.match(domain)
 
 	if matchedRefreshDomain != nil {
 		if matchedRefreshDomain.RefreshOn == models.REFRESH_ON_HIT && matchedRedirect != nil {
-			logger.Std.Printf("Refreshing config due to match with refresh domain %q and a redirect was found", domain)
-			manager.loadConfigUnsafe()
-			return
+			return fmt.Sprintf("match with refresh domain %q and a redirect was found", domain)
 		}
 
 		if matchedRefreshDomain.RefreshOn == models.REFRESH_ON_MISS && matchedRedirect == nil {
-			logger.Std.Printf("Refreshing config due to match with refresh domain %q and no redirect was found", domain)
-			manager.loadConfigUnsafe()
-			return
+			return fmt.Sprintf("match with refresh domain %q and no redirect was found", domain)
 		}
 	}
 
 	// Refresh config if refresh-on-hit is set and a redirect was found
-	if manager.config.UrlConfigRefresh.RefreshOnHit && matchedRedirect != nil {
-		logger.Std.Printf("Refreshing config due to refresh-on-hit and a redirect was found")
-		manager.loadConfigUnsafe()
-		return
+	if snapshot.config.UrlConfigRefresh.RefreshOnHit && matchedRedirect != nil {
+		return "refresh-on-hit and a redirect was found"
 	}
 
 	// Refresh config if refresh-on-miss is set and no redirect was found
-	if manager.config.UrlConfigRefresh.RefreshOnMiss && matchedRedirect == nil {
-		logger.Std.Printf("Refreshing config due to refresh-on-miss and no redirect was found")
-		manager.loadConfigUnsafe()
-		return
+	if snapshot.config.UrlConfigRefresh.RefreshOnMiss && matchedRedirect == nil {
+		return "refresh-on-miss and no redirect was found"
 	}
+
+	return ""
 }
 
+// Loads the config and swaps the snapshot, must only run on the active object
 func (manager *ConfigManager) loadConfigUnsafe() error {
-	var yamlBody []byte
-	var err error
+	current := manager.snapshot.Load()
+
+	next, err := manager.readAndLoadConfigUnsafe(current)
+
+	// Opened before the config is stored, so that a config whose access log cannot be opened is rejected as a whole
+	var accessLog *logger.AccessLog
+	if err == nil {
+		accessLog, err = logger.OpenAccessLog(next.config.AccessLog)
+	}
+
+	if err != nil {
+		configLoadsMetric.Inc("failure")
+
+		// Keep the current config, only record the failure
+		failed := *current
+		failed.lastLoadError = err
+		failed.lastLoadErrorAt = time.Now()
+		failed.failedLoads++
+		manager.snapshot.Store(&failed)
+
+		return err
+	}
+
+	configLoadsMetric.Inc("success")
+
+	next.failedLoads = current.failedLoads
+	manager.snapshot.Store(next)
+
+	// Logging options are global, so they are only applied once the config is in use
+	// Already validated
+	_ = logger.SetConfigLevel(next.config.LogLevel)
+	logger.UseAccessLog(accessLog)
+
+	return nil
+}
+
+// Result of the config loads
+type LoadStatus struct {
+	// Error of the last load if it failed (the current config is the last valid one), and the time it happened at
+	LastError   error
+	LastErrorAt time.Time
+
+	// Total number of failed loads
+	FailedLoads int
+}
+
+func (manager *ConfigManager) GetLoadStatus() LoadStatus {
+	snapshot := manager.snapshot.Load()
+
+	return LoadStatus{
+		LastError:   snapshot.lastLoadError,
+		LastErrorAt: snapshot.lastLoadErrorAt,
+		FailedLoads: snapshot.failedLoads,
+	}
+}
+
+// Reads and loads the config into a new snapshot, the current one is only used for its source and to keep unchanged parts
+// Nothing global is changed here, so a failed load leaves the running server as it is
+func (manager *ConfigManager) readAndLoadConfigUnsafe(current *configSnapshot) (*configSnapshot, error) {
+	source, uri := current.config.Source, current.config.ConfigURI
+
+	yamlBody, err := ReadConfig(source, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load into a new config so that the current one is kept if anything fails
+	newConfig := models.NewConfig(source, uri)
+	if err := newConfig.Load(yamlBody); err != nil {
+		return nil, err
+	}
+
+	certificates, err := loadCertificates(newConfig.Tls)
+	if err != nil {
+		return nil, fmt.Errorf("could not load TLS certificates: %w", err)
+	}
 
-	switch manager.config.Source {
+	// Tests guard the rules, so a config with failing tests is rejected before anything is applied
+	if diagnostics := newConfigSnapshot(newConfig, nil, nil).runTests(); diagnostics.HasErrors() {
+		return nil, fmt.Errorf("config tests failed:\n%s", diagnostics.ErrorMessages())
+	}
+
+	acmeManager, err := manager.getAcmeManager(current, newConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConfigSnapshot(newConfig, certificates, acmeManager), nil
+}
+
+// Reads the YAML config body from the given source
+func ReadConfig(source, uri string) ([]byte, error) {
+	switch source {
 	case models.SOURCE_STDIN:
-		yamlBody, err = io.ReadAll(os.Stdin)
-		if err != nil {
-			return err
-		}
+		return io.ReadAll(os.Stdin)
 
 	case models.SOURCE_FILE:
-		yamlBody, err = os.ReadFile(manager.config.ConfigURI)
-		if err != nil {
-			return err
-		}
+		return os.ReadFile(uri)
 
 	case models.SOURCE_URL:
-		res, err := http.Get(manager.config.ConfigURI)
+		res, err := http.Get(uri)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		yamlBody, err = io.ReadAll(res.Body)
-		if err != nil {
-			return err
-		}
+		defer res.Body.Close()
 
-		res.Body.Close()
+		return io.ReadAll(res.Body)
 	}
 
-	err = manager.config.Load(yamlBody)
-	return err
+	return nil, fmt.Errorf("unknown config source %q", source)
+}
+
+// Returns the ACME manager for the new config, the current manager is kept if ACME options did not change
+func (manager *ConfigManager) getAcmeManager(current *configSnapshot, newConfig *models.Config) (*autocert.Manager, error) {
+	if newConfig.Tls == nil || newConfig.Tls.Acme == nil {
+		return nil, nil
+	}
+
+	currentTls := current.config.Tls
+	if current.acme != nil && currentTls != nil && currentTls.Acme != nil && *currentTls.Acme == *newConfig.Tls.Acme {
+		return current.acme, nil
+	}
+
+	return newAcmeManager(newConfig.Tls.Acme, manager.acmeHostPolicy)
 }
 
 func (manager *ConfigManager) GetPort() int {
-	return active.RunCommandSync(
-		manager.active,
-		func() int { return manager.config.Port },
-	)
+	return manager.snapshot.Load().config.Port
+}
+
+// Returns the metrics options, or nil if metrics are not configured
+func (manager *ConfigManager) GetMetricsOptions() *models.MetricsOptions {
+	return manager.snapshot.Load().config.Metrics
+}
+
+// Returns the brute-force protection options, or nil if protection is not configured
+func (manager *ConfigManager) GetAuthProtection() *models.AuthProtectionOptions {
+	auth := manager.snapshot.Load().config.Auth
+	if auth == nil {
+		return nil
+	}
+
+	return auth.Protection
+}
+
+// Returns the OIDC auth that the request is the callback of, or nil if the request is not an OIDC callback
+func (manager *ConfigManager) MatchOIDCCallback(host, path string) *models.OIDCSchema {
+	return manager.snapshot.Load().matchOIDCCallback(host, path)
+}
+
+// The callback belongs to the redirect matched for the host and path, so that other redirects and proxied upstreams can use the same path
+// If no redirect matched, e.g. the OIDC redirect only covers other paths of the host, the redirects of the host are checked in order
+func (snapshot *configSnapshot) matchOIDCCallback(host, path string) *models.OIDCSchema {
+	if !snapshot.isOIDCCallbackPath(path) {
+		return nil
+	}
+
+	var candidates []*models.Redirect
+	if redirect := snapshot.matchRedirect(host, path); redirect != nil {
+		candidates = []*models.Redirect{redirect}
+	} else {
+		candidates = snapshot.redirects.matchHost(host, path)
+	}
+
+	for _, redirect := range candidates {
+		if auth := redirect.GetOIDC(); auth != nil && auth.CallbackPath == path {
+			return auth
+		}
+	}
+
+	return nil
+}
+
+// Checks whether the path is the callback path of any OIDC auth, to skip matching for other paths
+func (snapshot *configSnapshot) isOIDCCallbackPath(path string) bool {
+	auth := snapshot.config.Auth
+	if auth == nil {
+		return false
+	}
+
+	for _, oidcAuth := range auth.OIDC {
+		if oidcAuth.CallbackPath == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the HTTPS port, or 0 if TLS is not configured
+func (manager *ConfigManager) GetTlsPort() int {
+	tlsOptions := manager.snapshot.Load().config.Tls
+	if tlsOptions == nil {
+		return 0
+	}
+
+	return tlsOptions.Port
+}
+
+// Returns the certificate for the requested server name, to be used as tls.Config.GetCertificate
+// Configured certificates take precedence over ACME ones, and the first configured certificate is used if nothing else matched
+func (manager *ConfigManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	snapshot := manager.snapshot.Load()
+
+	matched := matchCertificate(hello.ServerName, snapshot.certificates)
+
+	var fallback *tls.Certificate
+	if len(snapshot.certificates.items) > 0 {
+		fallback = snapshot.certificates.items[0].certificate
+	}
+
+	// TLS-ALPN-01 challenges must always be answered by ACME
+	isAcmeChallenge := slices.Contains(hello.SupportedProtos, acme.ALPNProto)
+
+	if matched != nil && !isAcmeChallenge {
+		return matched, nil
+	}
+
+	if snapshot.acme != nil {
+		certificate, err := snapshot.acme.GetCertificate(hello)
+		if err == nil || isAcmeChallenge || fallback == nil {
+			return certificate, err
+		}
+	}
+
+	if fallback == nil {
+		return nil, fmt.Errorf("no certificate found for %q", hello.ServerName)
+	}
+
+	return fallback, nil
 }
 
 func (manager *ConfigManager) GetStringConfig() string {
-	return active.RunCommandSync(
-		manager.active,
-		func() string { return manager.config.String() },
-	)
+	return manager.snapshot.Load().config.String()
 }
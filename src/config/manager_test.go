@@ -1,18 +1,25 @@
 package config
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/AmrSaber/redirector/src/lib/logger"
 	"github.com/AmrSaber/redirector/src/models"
 )
 
-func BenchmarkGetRedirect(b *testing.B) {
-	manager := NewConfigManager("MANUAL_UPDATE", "")
-	defer manager.Close()
-	var wg sync.WaitGroup
+func createBenchmarkManager(b *testing.B) *ConfigManager {
+	manager := NewConfigManager(models.SOURCE_FILE, "")
+	b.Cleanup(manager.Close)
 
-	manager.config.Redirects = []models.Redirect{
+	config := models.NewConfig(models.SOURCE_FILE, "")
+	config.Redirects = []models.Redirect{
 		{
 			From: "a.b.c",
 			To:   "http://x.y.z",
@@ -23,13 +30,162 @@ func BenchmarkGetRedirect(b *testing.B) {
 		},
 	}
 
+	manager.snapshot.Store(newConfigSnapshot(config, nil, nil))
+
+	return manager
+}
+
+func BenchmarkGetRedirect(b *testing.B) {
+	manager := createBenchmarkManager(b)
+	var wg sync.WaitGroup
+
 	for n := 0; n < b.N; n++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			manager.GetRedirect("a.b.c")
+			manager.GetRedirect("a.b.c", "/")
 		}()
 	}
 
 	wg.Wait()
 }
+
+// Lookups read the current snapshot without waiting on each other, so this scales with GOMAXPROCS
+func BenchmarkGetRedirectParallel(b *testing.B) {
+	manager := createBenchmarkManager(b)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			manager.GetRedirect("a.b.c", "/")
+		}
+	})
+}
+
+func TestSlowUrlReloadDoesNotBlockLookups(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		<-release
+		res.Write([]byte("redirects:\n  - from: a.com\n    to: http://c.com\n"))
+	}))
+	defer server.Close()
+
+	manager := NewConfigManager(models.SOURCE_URL, server.URL)
+	defer manager.Close()
+
+	config := models.NewConfig(models.SOURCE_URL, server.URL)
+	if err := config.Load([]byte("redirects:\n  - from: a.com\n    to: http://b.com\n")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	manager.snapshot.Store(newConfigSnapshot(config, nil, nil))
+
+	reloadDone := make(chan error)
+	go func() { reloadDone <- manager.ReloadConfig() }()
+
+	// Lookups are served from the current snapshot while the reload is waiting on the server
+	lookupDone := make(chan *models.Redirect)
+	go func() { lookupDone <- manager.GetRedirect("a.com", "/") }()
+
+	select {
+	case redirect := <-lookupDone:
+		if redirect == nil || redirect.To != "http://b.com" {
+			t.Errorf("expected current config to be used, got %+v", redirect)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("lookup blocked by reload")
+	}
+
+	close(release)
+	if err := <-reloadDone; err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if redirect := manager.GetRedirect("a.com", "/"); redirect == nil || redirect.To != "http://c.com" {
+		t.Errorf("expected new config after reload, got %+v", redirect)
+	}
+}
+
+func TestAccessLogAppliedOnlyForStoredConfig(t *testing.T) {
+	dir := t.TempDir()
+	filePath := path.Join(dir, "config.yaml")
+	t.Cleanup(func() { logger.ConfigureAccessLog(nil) })
+
+	writeConfig := func(accessLogPath, target string) {
+		yamlConfig := `
+access-log:
+  output: ` + accessLogPath + `
+redirects:
+  - from: example.com
+    to: https://target.com
+tests:
+  - url: https://example.com
+    target: ` + target + `
+`
+
+		if err := os.WriteFile(filePath, []byte(yamlConfig), 0o644); err != nil {
+			t.Fatalf("could not write config file: %s", err)
+		}
+	}
+
+	manager := NewConfigManager(models.SOURCE_FILE, filePath)
+	defer manager.Close()
+
+	writeConfig(path.Join(dir, "access.log"), "https://target.com")
+	if err := manager.LoadConfig(); err != nil || !logger.IsAccessLogEnabled() {
+		t.Fatalf("expected access log to be enabled, got error %v", err)
+	}
+
+	// A rejected config does not get to open its access log
+	writeConfig(path.Join(dir, "missing", "access.log"), "https://other.com")
+	if err := manager.LoadConfig(); err == nil || !strings.Contains(err.Error(), "config tests failed") {
+		t.Errorf("expected failing tests error, got %v", err)
+	}
+
+	// A config whose access log cannot be opened is rejected, keeping the current config and access log
+	writeConfig(path.Join(dir, "missing", "access.log"), "https://target.com")
+	if err := manager.LoadConfig(); err == nil || !strings.Contains(err.Error(), "access log") {
+		t.Errorf("expected access log error, got %v", err)
+	}
+
+	if output := manager.GetConfig().AccessLog.Output; output != path.Join(dir, "access.log") {
+		t.Errorf("expected current config to be kept, got access log output %q", output)
+	}
+
+	if status := manager.GetLoadStatus(); status.LastError == nil || status.FailedLoads != 2 || !logger.IsAccessLogEnabled() {
+		t.Errorf("expected failed load to be recorded with the access log kept, got %+v", status)
+	}
+}
+
+func TestCheckConfigSkipsGlobalOptions(t *testing.T) {
+	dir := t.TempDir()
+	filePath := path.Join(dir, "config.yaml")
+	accessLogPath := path.Join(dir, "access.log")
+
+	os.WriteFile(filePath, []byte(`
+log-level: error
+access-log:
+  output: `+accessLogPath+`
+redirects:
+  - from: example.com
+    to: https://target.com
+`), 0o600)
+
+	level := logger.GetLevel()
+
+	manager, err := CheckConfigSource(false, filePath, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer manager.Close()
+
+	if manager.GetRedirect("example.com", "/") == nil {
+		t.Errorf("expected checked config to be loaded")
+	}
+
+	if _, err := os.Stat(accessLogPath); !os.IsNotExist(err) || logger.IsAccessLogEnabled() || logger.GetLevel() != level {
+		t.Errorf("expected log level and access log not to be applied")
+	}
+
+	if manager, err := CheckConfigSource(false, path.Join(dir, "missing.yaml"), ""); err == nil || manager != nil {
+		t.Errorf("expected error for missing config, got nil")
+	}
+}
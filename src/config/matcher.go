@@ -0,0 +1,259 @@
+package config
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/AmrSaber/redirector/src/models"
+	"github.com/AmrSaber/redirector/src/utils"
+)
+
+// Index of domain patterns, compiled once per config load
+// Exact domains are looked up in a map, and wildcard patterns in a trie of their reversed labels
+type domainMatcher struct {
+	exact    map[string][]int
+	wildcard *domainTrieNode
+}
+
+// Node of the reversed labels trie, e.g. "*.example.com" is stored under "com" -> "example" -> "*"
+type domainTrieNode struct {
+	children map[string]*domainTrieNode
+
+	// Indexes of the patterns that end at this node
+	patterns []int
+
+	// Patterns that have "**" at this node, the labels before "**" are matched against the start of the domain
+	multiLabelPatterns []multiLabelPattern
+}
+
+type multiLabelPattern struct {
+	index  int
+	before []string
+}
+
+// Creates a matcher for the given domain patterns, matches are reported by their index in the list
+// Empty patterns never match
+func newDomainMatcher(domains []string) *domainMatcher {
+	matcher := &domainMatcher{
+		exact:    make(map[string][]int),
+		wildcard: newDomainTrieNode(),
+	}
+
+	for i, domain := range domains {
+		if domain == "" {
+			continue
+		}
+
+		if !strings.Contains(domain, "*") {
+			matcher.exact[domain] = append(matcher.exact[domain], i)
+			continue
+		}
+
+		matcher.wildcard.insert(i, strings.Split(domain, "."))
+	}
+
+	return matcher
+}
+
+func newDomainTrieNode() *domainTrieNode {
+	return &domainTrieNode{children: make(map[string]*domainTrieNode)}
+}
+
+func (node *domainTrieNode) insert(index int, patternParts []string) {
+	suffix := patternParts
+	var before []string
+
+	// At most one "**" is allowed, and it is validated on load
+	multiIndex := slices.Index(patternParts, "**")
+	if multiIndex != -1 {
+		suffix, before = patternParts[multiIndex+1:], patternParts[:multiIndex]
+	}
+
+	current := node
+	for i := len(suffix) - 1; i >= 0; i-- {
+		child, ok := current.children[suffix[i]]
+		if !ok {
+			child = newDomainTrieNode()
+			current.children[suffix[i]] = child
+		}
+
+		current = child
+	}
+
+	if multiIndex == -1 {
+		current.patterns = append(current.patterns, index)
+	} else {
+		current.multiLabelPatterns = append(current.multiLabelPatterns, multiLabelPattern{index: index, before: before})
+	}
+}
+
+// Collects the indexes of the patterns matching the domain parts, remaining is the count of parts not consumed yet
+func (node *domainTrieNode) collect(domainParts []string, remaining int, matches []int) []int {
+	if remaining == 0 {
+		matches = append(matches, node.patterns...)
+	}
+
+	for _, pattern := range node.multiLabelPatterns {
+		// "**" must match at least one part
+		if remaining > len(pattern.before) && matchDomainParts(pattern.before, domainParts[:len(pattern.before)]) {
+			matches = append(matches, pattern.index)
+		}
+	}
+
+	if remaining == 0 {
+		return matches
+	}
+
+	label := domainParts[remaining-1]
+
+	if child, ok := node.children[label]; ok && label != "*" {
+		matches = child.collect(domainParts, remaining-1, matches)
+	}
+
+	if child, ok := node.children["*"]; ok {
+		matches = child.collect(domainParts, remaining-1, matches)
+	}
+
+	return matches
+}
+
+// Returns the indexes of the patterns exactly equal to the domain, in order
+func (matcher *domainMatcher) matchExact(domain string) []int {
+	return matcher.exact[domain]
+}
+
+// Returns the indexes of the wildcard patterns matching the domain parts, in order
+func (matcher *domainMatcher) matchWildcard(domainParts []string) []int {
+	matches := matcher.wildcard.collect(domainParts, len(domainParts), nil)
+	slices.Sort(matches)
+
+	return matches
+}
+
+// Returns the index of the first matching pattern, exact matches take precedence over wildcard ones
+// Returns -1 if nothing matched
+func (matcher *domainMatcher) match(domain string) int {
+	domain = utils.StripPort(domain)
+
+	if exact := matcher.matchExact(domain); len(exact) > 0 {
+		return exact[0]
+	}
+
+	if wildcard := matcher.matchWildcard(strings.Split(domain, ".")); len(wildcard) > 0 {
+		return wildcard[0]
+	}
+
+	return -1
+}
+
+// List of items indexed by their domain
+type domainIndex[T any] struct {
+	items   []T
+	matcher *domainMatcher
+}
+
+func newDomainIndex[T any](items []T, mapper func(T) string) *domainIndex[T] {
+	domains := make([]string, len(items))
+	for i, item := range items {
+		domains[i] = mapper(item)
+	}
+
+	return &domainIndex[T]{items: items, matcher: newDomainMatcher(domains)}
+}
+
+// Returns a pointer to the item that matched the domain, or nil if none matched
+func (index *domainIndex[T]) match(domain string) *T {
+	i := index.matcher.match(domain)
+	if i == -1 {
+		return nil
+	}
+
+	return &index.items[i]
+}
+
+// Redirects indexed by their domain, regex redirects are kept aside and checked in order
+type redirectMatcher struct {
+	redirects    []models.Redirect
+	domains      *domainMatcher
+	regexIndexes []int
+}
+
+func newRedirectMatcher(redirects []models.Redirect) *redirectMatcher {
+	domains := make([]string, len(redirects))
+	regexIndexes := make([]int, 0)
+
+	for i, r := range redirects {
+		if r.FromRegex != "" {
+			regexIndexes = append(regexIndexes, i)
+			continue
+		}
+
+		domains[i] = r.From
+	}
+
+	return &redirectMatcher{
+		redirects:    redirects,
+		domains:      newDomainMatcher(domains),
+		regexIndexes: regexIndexes,
+	}
+}
+
+// Returns a pointer to the redirect that best matches the given host and path
+// Exact host matches take precedence over wildcard ones, then the most specific path wins, then the first in order
+// Regex redirects are only considered if no domain redirect matched, and the first matching one is returned
+func (matcher *redirectMatcher) match(host, requestPath string) *models.Redirect {
+	host = utils.StripPort(host)
+
+	if exactMatch := matcher.matchMostSpecificPath(matcher.domains.matchExact(host), requestPath); exactMatch != nil {
+		return exactMatch
+	}
+
+	wildcardIndexes := matcher.domains.matchWildcard(strings.Split(host, "."))
+	if wildcardMatch := matcher.matchMostSpecificPath(wildcardIndexes, requestPath); wildcardMatch != nil {
+		return wildcardMatch
+	}
+
+	for _, i := range matcher.regexIndexes {
+		if matcher.redirects[i].MatchRegex(host, requestPath) {
+			return &matcher.redirects[i]
+		}
+	}
+
+	return nil
+}
+
+// Returns the redirects whose domain matches the host regardless of their paths, in order of precedence
+// Regex redirects also match paths, so they are matched with the given path
+func (matcher *redirectMatcher) matchHost(host, requestPath string) []*models.Redirect {
+	host = utils.StripPort(host)
+
+	indexes := slices.Concat(matcher.domains.matchExact(host), matcher.domains.matchWildcard(strings.Split(host, ".")))
+	for _, i := range matcher.regexIndexes {
+		if matcher.redirects[i].MatchRegex(host, requestPath) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	redirects := make([]*models.Redirect, len(indexes))
+	for j, i := range indexes {
+		redirects[j] = &matcher.redirects[i]
+	}
+
+	return redirects
+}
+
+// Returns the redirect with the most specific path out of the given ones, indexes must be in order
+func (matcher *redirectMatcher) matchMostSpecificPath(indexes []int, requestPath string) *models.Redirect {
+	var bestMatch *models.Redirect
+	bestSpecificity := -1
+
+	for _, i := range indexes {
+		// Strict comparison keeps the first redirect on ties
+		if isMatch, specificity := matcher.redirects[i].MatchPath(requestPath); isMatch && specificity > bestSpecificity {
+			bestMatch = &matcher.redirects[i]
+			bestSpecificity = specificity
+		}
+	}
+
+	return bestMatch
+}
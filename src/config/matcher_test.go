@@ -0,0 +1,171 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AmrSaber/redirector/src/models"
+	"github.com/AmrSaber/redirector/src/utils"
+)
+
+// Reference implementation scanning all the patterns in order
+func matchDomainLinear(domain string, patterns []string) int {
+	domainParts := strings.Split(domain, ".")
+
+	for i, pattern := range patterns {
+		if pattern == domain {
+			return i
+		}
+	}
+
+	for i, pattern := range patterns {
+		if !strings.Contains(pattern, "*") {
+			continue
+		}
+
+		patternParts := strings.Split(pattern, ".")
+		multiIndex := slices.Index(patternParts, "**")
+
+		if multiIndex == -1 {
+			if len(patternParts) == len(domainParts) && matchDomainParts(patternParts, domainParts) {
+				return i
+			}
+
+			continue
+		}
+
+		before, after := patternParts[:multiIndex], patternParts[multiIndex+1:]
+		if len(domainParts) >= len(patternParts) &&
+			matchDomainParts(before, domainParts[:len(before)]) &&
+			matchDomainParts(after, domainParts[len(domainParts)-len(after):]) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func TestDomainMatcherPrecedence(t *testing.T) {
+	patterns := []string{
+		"*.b.example.com",
+		"a.*.example.com",
+		"**.example.com",
+		"a.b.example.com",
+		"x.**.example.com",
+		"*.*.example.com",
+		"x.**",
+		"*.example.*",
+		"**.b.*.com",
+		"example.com",
+		"*.example.com",
+	}
+
+	domains := []string{
+		"a.b.example.com",
+		"c.b.example.com",
+		"a.c.example.com",
+		"c.c.example.com",
+		"x.y.z.example.com",
+		"x.example.com",
+		"x.y",
+		"y.example.org",
+		"q.b.w.com",
+		"example.com",
+		"b.example.com",
+		"a.b.example.com:8080",
+		"unknown.org",
+		"com",
+	}
+
+	// Every suffix of the patterns list checks a different precedence
+	for start := range patterns {
+		matcher := newDomainMatcher(patterns[start:])
+
+		for _, domain := range domains {
+			expected := matchDomainLinear(utils.StripPort(domain), patterns[start:])
+			if got := matcher.match(domain); got != expected {
+				t.Errorf("patterns %v, domain %q: expected %d, got %d", patterns[start:], domain, expected, got)
+			}
+		}
+	}
+}
+
+func TestRedirectMatcherWildcardPaths(t *testing.T) {
+	redirects := []models.Redirect{
+		{From: "*.example.com", Path: "/", To: "http://first.com"},
+		{From: "**.example.com", Path: "/docs", To: "http://docs.com"},
+		{From: "a.*.com", Path: "/docs", To: "http://tie.com"},
+	}
+
+	// The most specific path across all matching wildcards wins, then the first in order
+	redirect := newRedirectMatcher(redirects).match("a.example.com", "/docs/page")
+	if redirect == nil || redirect.To != "http://docs.com" {
+		t.Errorf("expected docs redirect, got %+v", redirect)
+	}
+
+	redirect = newRedirectMatcher(redirects).match("a.example.com", "/")
+	if redirect == nil || redirect.To != "http://first.com" {
+		t.Errorf("expected first redirect, got %+v", redirect)
+	}
+}
+
+func TestMatchIgnoresPort(t *testing.T) {
+	matcher := newDomainMatcher([]string{"example.com", "127.0.0.1", "*.example.com"})
+
+	testCases := map[string]int{
+		"example.com":       0,
+		"example.com:8080":  0,
+		"127.0.0.1:80":      1,
+		"a.example.com:443": 2,
+		"example.com:abc":   0,
+		"other.com:8080":    -1,
+	}
+
+	for domain, expected := range testCases {
+		if got := matcher.match(domain); got != expected {
+			t.Errorf("%q: expected %d, got %d", domain, expected, got)
+		}
+	}
+}
+
+// Rules are a mix of exact, single-label and multi-label wildcard domains
+func createBenchmarkRedirects(count int) []models.Redirect {
+	redirects := make([]models.Redirect, count)
+
+	for i := range redirects {
+		switch i % 3 {
+		case 0:
+			redirects[i] = models.Redirect{From: fmt.Sprintf("domain-%d.example.com", i), To: "http://target.com"}
+		case 1:
+			redirects[i] = models.Redirect{From: fmt.Sprintf("*.wild-%d.example.com", i), To: "http://target.com"}
+		case 2:
+			redirects[i] = models.Redirect{From: fmt.Sprintf("**.multi-%d.example.com", i), To: "http://target.com"}
+		}
+	}
+
+	return redirects
+}
+
+func BenchmarkRedirectMatcher(b *testing.B) {
+	for _, count := range []int{10, 1_000, 100_000} {
+		matcher := newRedirectMatcher(createBenchmarkRedirects(count))
+
+		// Hosts matching the last rules of each kind, and a miss
+		hosts := map[string]string{
+			"exact":    fmt.Sprintf("domain-%d.example.com", (count-1)/3*3),
+			"wildcard": fmt.Sprintf("a.wild-%d.example.com", (count-2)/3*3+1),
+			"multi":    fmt.Sprintf("a.b.multi-%d.example.com", (count-3)/3*3+2),
+			"miss":     "a.unknown.example.com",
+		}
+
+		for name, host := range hosts {
+			b.Run(fmt.Sprintf("%d/%s", count, name), func(b *testing.B) {
+				for n := 0; n < b.N; n++ {
+					matcher.match(host, "/")
+				}
+			})
+		}
+	}
+}
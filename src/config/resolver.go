@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/AmrSaber/redirector/src/models"
+)
+
+// Checks the OIDC session of the request, returns the status code the auth responds with if the request is not allowed, or 0 if it is
+type OIDCSessionChecker func(req *http.Request, auth *models.OIDCSchema) int
+
+// Resolves requests against a set of rules
+type RequestResolver interface {
+	Resolve(req *http.Request, checkOIDCSession OIDCSessionChecker) models.Resolution
+}
+
+// Rules of a config that is only loaded to resolve requests against, none of its options are applied and its tests are not run
+type ConfigRules struct {
+	snapshot *configSnapshot
+}
+
+// Reads and loads the config from the given source to resolve requests against, like validation it has no side effects
+func LoadConfigRules(source, uri string) (*ConfigRules, error) {
+	yamlBody, err := ReadConfig(source, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	config := models.NewConfig(source, uri)
+	if err := config.Load(yamlBody); err != nil {
+		return nil, err
+	}
+
+	return &ConfigRules{snapshot: newConfigSnapshot(config, nil, nil)}, nil
+}
+
+func (rules *ConfigRules) Resolve(req *http.Request, checkOIDCSession OIDCSessionChecker) models.Resolution {
+	return rules.snapshot.resolve(req, checkOIDCSession)
+}
+
+// Resolves the request against the current rules the same way the redirection handler does, without serving it
+// Credentials are checked for basic auth, jwt and API keys, forward auth needs a call to its endpoint so it's not checked
+// OIDC sessions are checked with the given checker, requests are considered without a session if it's nil
+// Unlike GetRedirect, resolving never triggers a config refresh
+func (manager *ConfigManager) Resolve(req *http.Request, checkOIDCSession OIDCSessionChecker) models.Resolution {
+	return manager.snapshot.Load().resolve(req, checkOIDCSession)
+}
+
+func (snapshot *configSnapshot) resolve(req *http.Request, checkOIDCSession OIDCSessionChecker) models.Resolution {
+	resolution := models.Resolution{URL: req.URL.String()}
+
+	if snapshot.matchOIDCCallback(req.Host, req.URL.Path) != nil {
+		resolution.Note = "OIDC callback path, handled by redirector after login"
+		return resolution
+	}
+
+	redirect := snapshot.matchRedirect(req.Host, req.URL.Path)
+	if redirect == nil {
+		resolution.Status = http.StatusNotFound
+		resolution.Note = "could not match host to any redirection rule"
+		return resolution
+	}
+
+	index := redirect.GetIndex()
+	resolution.Matched = true
+	resolution.RuleIndex = &index
+	resolution.RuleName = redirect.GetName()
+	resolution.Mode = redirect.Mode
+	resolution.Auth = redirect.AuthNames
+	resolution.Target = redirect.ResolvePath(req)
+
+	if redirect.GetForwardAuth() != nil {
+		resolution.Note = "authorization is decided by the forward auth endpoint"
+		return resolution
+	}
+
+	if oidcAuth := redirect.GetOIDC(); oidcAuth != nil {
+		status := http.StatusFound
+		if checkOIDCSession != nil {
+			status = checkOIDCSession(req, oidcAuth)
+		}
+
+		switch status {
+		case 0:
+		case http.StatusFound:
+			resolution.Status = status
+			resolution.Note = "no valid session, users are sent to log in with the OIDC provider"
+			return resolution
+		default:
+			resolution.Status = status
+			resolution.Note = "user of the session is not allowed"
+			return resolution
+		}
+	} else if !redirect.IsAuthorized(req) {
+		resolution.Status = http.StatusUnauthorized
+		resolution.Note = "credentials are missing or not accepted"
+		return resolution
+	}
+
+	resolution.Authorized = true
+
+	if redirect.Mode == models.MODE_PROXY {
+		resolution.Note = "proxied, the status is decided by the upstream server"
+		return resolution
+	}
+
+	resolution.Status = redirect.GetStatusCode()
+
+	return resolution
+}
+
+// Creates a request to resolve for the given URL, with the given headers
+func NewResolveRequest(rawUrl string, headers map[string][]string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", rawUrl, err)
+	}
+
+	if req.URL.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: host is missing", rawUrl)
+	}
+
+	for name, values := range headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	return req, nil
+}
+
+// Runs the tests of the config against its rules, OIDC sessions cannot be given in tests
+func (snapshot *configSnapshot) runTests() models.Diagnostics {
+	diagnostics := models.Diagnostics{}
+
+	for i, test := range snapshot.config.Tests {
+		headers := map[string][]string{}
+		for name, value := range test.Headers {
+			headers[name] = []string{value}
+		}
+
+		var reason string
+		if req, err := NewResolveRequest(test.URL, headers); err != nil {
+			reason = err.Error()
+		} else {
+			reason = test.Check(snapshot.resolve(req, nil))
+		}
+
+		if reason != "" {
+			diagnostics = append(diagnostics, models.NewDiagnostic(
+				models.SEVERITY_ERROR,
+				"test-failed",
+				[]any{"tests", i},
+				fmt.Sprintf("Test #%d (%s) failed: %s", i, test.GetName(), reason),
+			))
+		}
+	}
+
+	return diagnostics
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/AmrSaber/redirector/src/models"
+)
+
+func TestConfigTestsOnReload(t *testing.T) {
+	filePath := path.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(target string) {
+		yamlConfig := `
+redirects:
+  - from: example.com
+    path: /login
+    to: ` + target + `
+  - from: private.com
+    to: https://internal.com
+    auth: [team]
+auth:
+  basic-auth:
+    team:
+      users:
+        - username: alice
+          password: secret
+tests:
+  - name: login
+    url: https://example.com/login
+    target: https://auth.com/login
+  - url: https://private.com
+    status: 401
+  - url: https://private.com
+    headers:
+      Authorization: Basic YWxpY2U6c2VjcmV0
+    target: https://internal.com
+  - url: https://unknown.com
+    target: "404"
+`
+
+		if err := os.WriteFile(filePath, []byte(yamlConfig), 0o644); err != nil {
+			t.Fatalf("could not write config file: %s", err)
+		}
+	}
+
+	manager := NewConfigManager(models.SOURCE_FILE, filePath)
+	defer manager.Close()
+
+	writeConfig("https://auth.com/login")
+	if err := manager.LoadConfig(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Test a reload with failing tests is rejected and the previous config is kept
+	writeConfig("https://other.com/login")
+	err := manager.ReloadConfig()
+	if err == nil || !strings.Contains(err.Error(), "Test #0 (login) failed") {
+		t.Errorf("expected failing test error, got %v", err)
+	}
+
+	if redirect := manager.GetRedirect("example.com", "/login"); redirect == nil || redirect.To != "https://auth.com/login" {
+		t.Errorf("expected previous config to be kept, got %+v", redirect)
+	}
+
+	// Test the failing test is reported by validation at its position
+	yamlBody, _ := os.ReadFile(filePath)
+	diagnostics := ValidateConfig(yamlBody)
+	if len(diagnostics) != 1 || diagnostics[0].Code != "test-failed" || diagnostics[0].Path != "tests[0]" || diagnostics[0].Line == 0 {
+		t.Errorf("expected failing test diagnostic, got %+v", diagnostics)
+	}
+
+}
+
+func TestLoadConfigRules(t *testing.T) {
+	dir := t.TempDir()
+	filePath := path.Join(dir, "config.yaml")
+
+	// Failing tests and options with side effects, such as an access log that cannot be opened, do not prevent resolving
+	yamlConfig := `
+access-log:
+  output: ` + path.Join(dir, "missing", "access.log") + `
+redirects:
+  - from: example.com
+    to: https://target.com
+tests:
+  - url: https://example.com
+    target: https://other.com
+`
+
+	if err := os.WriteFile(filePath, []byte(yamlConfig), 0o644); err != nil {
+		t.Fatalf("could not write config file: %s", err)
+	}
+
+	rules, err := LoadConfigRules(models.SOURCE_FILE, filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	req, _ := NewResolveRequest("https://example.com", nil)
+	if resolution := rules.Resolve(req, nil); resolution.Target != "https://target.com" {
+		t.Errorf("unexpected resolution %+v", resolution)
+	}
+
+	if _, err := LoadConfigRules(models.SOURCE_FILE, path.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("expected error for missing config file, got nil")
+	}
+}
+
+func TestNewResolveRequest(t *testing.T) {
+	req, err := NewResolveRequest("https://example.com:8080/path?x=1", map[string][]string{"X-Token": {"a", "b"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if req.Host != "example.com:8080" || req.URL.Path != "/path" || len(req.Header.Values("X-Token")) != 2 {
+		t.Errorf("unexpected request: %s %v", req.URL, req.Header)
+	}
+
+	for _, rawUrl := range []string{"example.com/path", "https://exa mple.com"} {
+		if _, err := NewResolveRequest(rawUrl, nil); err == nil {
+			t.Errorf("%q: expected error, got nil", rawUrl)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/AmrSaber/redirector/src/models"
+)
+
+// Validates the YAML config without applying it, including what is only checked when loading such as TLS certificates and config tests
+func ValidateConfig(yamlBody []byte) models.Diagnostics {
+	return models.Validate(yamlBody, func(models.Config) models.Diagnostics {
+		config := models.NewConfig(models.SOURCE_STDIN, "")
+		if err := config.Load(yamlBody); err != nil {
+			// Should not happen as the config was already validated
+			return models.Diagnostics{models.NewDiagnostic(models.SEVERITY_ERROR, "invalid-config", nil, err.Error())}
+		}
+
+		diagnostics := models.Diagnostics{}
+
+		if _, err := loadCertificates(config.Tls); err != nil {
+			diagnostics = append(diagnostics, models.NewDiagnostic(
+				models.SEVERITY_ERROR,
+				"invalid-certificate",
+				[]any{"tls"},
+				fmt.Sprintf("Could not load TLS certificates: %s", err),
+			))
+		}
+
+		return append(diagnostics, newConfigSnapshot(config, nil, nil).runTests()...)
+	})
+}
@@ -0,0 +1,204 @@
+package bruteforce
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracks failed auth attempts per client IP and per username, delaying and then locking out repeated failures
+
+type Options struct {
+	// Failures after which the client IP or the username is locked out
+	MaxIPFailures   int
+	MaxUserFailures int
+
+	// Failures are forgotten after this duration without new failures
+	Window time.Duration
+
+	// How long a lockout lasts
+	Lockout time.Duration
+
+	// Delay of the first failed attempt, doubled on each subsequent failure up to max delay
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// Kinds of tracked keys
+const (
+	KIND_IP   = "ip"
+	KIND_USER = "user"
+)
+
+type record struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+// Records kept per kind, so that attempts with random usernames or addresses cannot grow the records without limit
+const defaultMaxRecords = 100_000
+
+type Guard struct {
+	lock        sync.Mutex
+	records     map[string]map[string]*record
+	lastSweepAt time.Time
+
+	// Replaced in tests
+	now        func() time.Time
+	maxRecords int
+}
+
+// Result of a failed attempt
+type Failure struct {
+	// How long to wait before responding
+	Delay time.Duration
+
+	// Set if the failure caused a lockout
+	LockedFor time.Duration
+
+	// Kinds of keys that got locked out by this failure
+	LockedKinds []string
+}
+
+func NewGuard() *Guard {
+	return &Guard{
+		records: map[string]map[string]*record{
+			KIND_IP:   make(map[string]*record),
+			KIND_USER: make(map[string]*record),
+		},
+		now:        time.Now,
+		maxRecords: defaultMaxRecords,
+	}
+}
+
+// Returns the remaining lockout of the client IP or the username, or 0 if neither is locked out
+// Empty username is not tracked
+func (guard *Guard) GetLockout(ip, username string) time.Duration {
+	guard.lock.Lock()
+	defer guard.lock.Unlock()
+
+	now := guard.now()
+	remaining := time.Duration(0)
+
+	for kind, key := range guard.keys(ip, username) {
+		if r, ok := guard.records[kind][key]; ok && r.lockedUntil.After(now) {
+			remaining = max(remaining, r.lockedUntil.Sub(now))
+		}
+	}
+
+	return remaining
+}
+
+// Records a failed attempt of the client IP and the username
+func (guard *Guard) RecordFailure(options Options, ip, username string) Failure {
+	guard.lock.Lock()
+	defer guard.lock.Unlock()
+
+	now := guard.now()
+	guard.sweep(options, now)
+
+	var failure Failure
+	maxFailures := 0
+
+	for kind, key := range guard.keys(ip, username) {
+		r, ok := guard.records[kind][key]
+		if !ok && !guard.makeRoom(kind, options, now) {
+			// Only locked out keys are kept when full, new keys are not tracked until some of them expire
+			continue
+		}
+
+		if !ok || now.Sub(r.lastFailureAt) > options.Window {
+			r = &record{}
+			guard.records[kind][key] = r
+		}
+
+		r.failures++
+		r.lastFailureAt = now
+		maxFailures = max(maxFailures, r.failures)
+
+		limit := options.MaxIPFailures
+		if kind == KIND_USER {
+			limit = options.MaxUserFailures
+		}
+
+		if limit > 0 && r.failures >= limit {
+			r.failures = 0
+			r.lockedUntil = now.Add(options.Lockout)
+
+			failure.LockedFor = options.Lockout
+			failure.LockedKinds = append(failure.LockedKinds, kind)
+		}
+	}
+
+	// No delay is needed when locked out, as further attempts are rejected anyway
+	if failure.LockedFor == 0 && maxFailures > 0 {
+		failure.Delay = options.Delay
+		for i := 1; i < maxFailures && failure.Delay < options.MaxDelay; i++ {
+			failure.Delay *= 2
+		}
+
+		failure.Delay = min(failure.Delay, options.MaxDelay)
+	}
+
+	return failure
+}
+
+// Forgets the failures of the username after a successful attempt
+// Client IP failures are kept, so that one valid account does not reset the attempts on other accounts
+func (guard *Guard) RecordSuccess(username string) {
+	guard.lock.Lock()
+	defer guard.lock.Unlock()
+
+	delete(guard.records[KIND_USER], username)
+}
+
+func (guard *Guard) keys(ip, username string) map[string]string {
+	keys := map[string]string{KIND_IP: ip}
+	if username != "" {
+		keys[KIND_USER] = username
+	}
+
+	return keys
+}
+
+// Removes expired records, at most once per window
+func (guard *Guard) sweep(options Options, now time.Time) {
+	if now.Sub(guard.lastSweepAt) < options.Window {
+		return
+	}
+
+	guard.lastSweepAt = now
+
+	for _, records := range guard.records {
+		removeRecords(records, func(r *record) bool {
+			return now.Sub(r.lastFailureAt) > options.Window && !r.lockedUntil.After(now)
+		})
+	}
+}
+
+// Makes room for a new record of the kind if the records are full, returns whether there is room
+// Expired records are removed first, then the failures of keys that are not locked out are forgotten
+func (guard *Guard) makeRoom(kind string, options Options, now time.Time) bool {
+	records := guard.records[kind]
+	if len(records) < guard.maxRecords {
+		return true
+	}
+
+	removeRecords(records, func(r *record) bool {
+		return now.Sub(r.lastFailureAt) > options.Window && !r.lockedUntil.After(now)
+	})
+
+	if len(records) >= guard.maxRecords {
+		removeRecords(records, func(r *record) bool { return !r.lockedUntil.After(now) })
+	}
+
+	return len(records) < guard.maxRecords
+}
+
+func removeRecords(records map[string]*record, shouldRemove func(r *record) bool) {
+	for key, r := range records {
+		if shouldRemove(r) {
+			delete(records, key)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package bruteforce
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGuardDelayAndLockout(t *testing.T) {
+	now := time.Now()
+	guard := NewGuard()
+	guard.now = func() time.Time { return now }
+
+	options := Options{
+		MaxIPFailures:   5,
+		MaxUserFailures: 3,
+		Window:          time.Minute,
+		Lockout:         10 * time.Minute,
+		Delay:           100 * time.Millisecond,
+		MaxDelay:        150 * time.Millisecond,
+	}
+
+	// Test exponential delay up to max delay
+	if failure := guard.RecordFailure(options, "1.1.1.1", "admin"); failure.Delay != 100*time.Millisecond || failure.LockedFor != 0 {
+		t.Errorf("unexpected first failure: %+v", failure)
+	}
+
+	if failure := guard.RecordFailure(options, "1.1.1.1", "admin"); failure.Delay != 150*time.Millisecond || failure.LockedFor != 0 {
+		t.Errorf("unexpected second failure: %+v", failure)
+	}
+
+	// Test username lockout
+	failure := guard.RecordFailure(options, "2.2.2.2", "admin")
+	if failure.LockedFor != options.Lockout || len(failure.LockedKinds) != 1 || failure.LockedKinds[0] != KIND_USER {
+		t.Errorf("expected username lockout, got %+v", failure)
+	}
+
+	if lockout := guard.GetLockout("3.3.3.3", "admin"); lockout != options.Lockout {
+		t.Errorf("expected username to be locked out for %s, got %s", options.Lockout, lockout)
+	}
+
+	if lockout := guard.GetLockout("1.1.1.1", "other"); lockout != 0 {
+		t.Errorf("expected client IP not to be locked out, got %s", lockout)
+	}
+
+	// Test lockout expiry
+	now = now.Add(options.Lockout)
+	if lockout := guard.GetLockout("3.3.3.3", "admin"); lockout != 0 {
+		t.Errorf("expected lockout to expire, got %s", lockout)
+	}
+
+	// Test client IP lockout across usernames, older failures are forgotten after the window
+	guard.RecordFailure(options, "1.1.1.1", "a")
+	guard.RecordFailure(options, "1.1.1.1", "b")
+
+	now = now.Add(2 * options.Window)
+	for i := 0; i < 4; i++ {
+		guard.RecordFailure(options, "1.1.1.1", string(rune('c'+i)))
+	}
+
+	if lockout := guard.GetLockout("1.1.1.1", ""); lockout != 0 {
+		t.Errorf("expected client IP not to be locked out yet, got %s", lockout)
+	}
+
+	failure = guard.RecordFailure(options, "1.1.1.1", "")
+	if failure.LockedFor != options.Lockout || failure.LockedKinds[0] != KIND_IP {
+		t.Errorf("expected client IP lockout, got %+v", failure)
+	}
+
+	// Test success resets the username failures only
+	guard.RecordFailure(options, "4.4.4.4", "user")
+	guard.RecordFailure(options, "4.4.4.4", "user")
+	guard.RecordSuccess("user")
+
+	if failure := guard.RecordFailure(options, "5.5.5.5", "user"); failure.LockedFor != 0 || failure.Delay != options.Delay {
+		t.Errorf("expected username failures to be reset, got %+v", failure)
+	}
+}
+
+func TestGuardDisabledLockout(t *testing.T) {
+	guard := NewGuard()
+	options := Options{Window: time.Minute, Lockout: time.Minute}
+
+	for i := 0; i < 100; i++ {
+		if failure := guard.RecordFailure(options, "1.1.1.1", "admin"); failure.LockedFor != 0 {
+			t.Fatalf("expected no lockout when limits are 0, got %+v", failure)
+		}
+	}
+}
+
+func TestGuardMaxRecords(t *testing.T) {
+	now := time.Now()
+	guard := NewGuard()
+	guard.now = func() time.Time { return now }
+	guard.maxRecords = 3
+
+	options := Options{MaxIPFailures: 0, MaxUserFailures: 2, Window: time.Minute, Lockout: time.Hour}
+
+	// Lock a username out, then fill the records with random usernames
+	guard.RecordFailure(options, "1.1.1.1", "admin")
+	guard.RecordFailure(options, "1.1.1.1", "admin")
+
+	for i := 0; i < 10; i++ {
+		guard.RecordFailure(options, "1.1.1.1", string(rune('a'+i)))
+
+		if count := len(guard.records[KIND_USER]); count > guard.maxRecords {
+			t.Fatalf("expected at most %d records, got %d", guard.maxRecords, count)
+		}
+	}
+
+	// Locked out keys are kept when making room
+	if lockout := guard.GetLockout("2.2.2.2", "admin"); lockout == 0 {
+		t.Errorf("expected username to stay locked out")
+	}
+}
@@ -0,0 +1,316 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Minimal implementation of JSON web tokens (RFC 7519) verification, with signed tokens (JWS) only
+
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	Type      string `json:"typ,omitempty"`
+}
+
+type Claims map[string]any
+
+// Supported signing algorithms
+var Algorithms = []string{
+	"HS256", "HS384", "HS512",
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+type Token struct {
+	Header Header
+	Claims Claims
+
+	signingInput string
+	signature    []byte
+}
+
+var (
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrNoMatchingKey    = errors.New("no key matches the token")
+
+	errKeyTypeMismatch = errors.New("key type does not match the algorithm")
+)
+
+// Parses the token without verifying it
+func Parse(raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid token: expected 3 sections, found %d", len(parts))
+	}
+
+	token := &Token{signingInput: parts[0] + "." + parts[1]}
+
+	if err := decodeSection(parts[0], &token.Header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+
+	if err := decodeSection(parts[1], &token.Claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature encoding: %w", err)
+	}
+	token.signature = signature
+
+	return token, nil
+}
+
+func decodeSection(section string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(section)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+
+	return decoder.Decode(target)
+}
+
+// Verifies the token signature with the matching key of the set
+// Keys are matched by key ID if the token has one, otherwise all the keys that support the algorithm are tried
+func (token *Token) Verify(keys KeySet) error {
+	if token.Header.Algorithm == "" || token.Header.Algorithm == "none" {
+		return fmt.Errorf("unsigned tokens are not accepted")
+	}
+
+	found := false
+	for _, key := range keys {
+		if token.Header.KeyID != "" && key.ID != "" && key.ID != token.Header.KeyID {
+			continue
+		}
+
+		if key.Algorithm != "" && key.Algorithm != token.Header.Algorithm {
+			continue
+		}
+
+		err := verifySignature(token.Header.Algorithm, key.Key, []byte(token.signingInput), token.signature)
+		if errors.Is(err, errKeyTypeMismatch) {
+			continue
+		}
+
+		found = true
+		if err == nil {
+			return nil
+		} else if !errors.Is(err, ErrInvalidSignature) {
+			return err
+		}
+	}
+
+	if !found {
+		return ErrNoMatchingKey
+	}
+
+	return ErrInvalidSignature
+}
+
+func verifySignature(algorithm string, key any, signingInput, signature []byte) error {
+	hashes := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+
+	if algorithm == "EdDSA" {
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s requires an Ed25519 key: %w", algorithm, errKeyTypeMismatch)
+		}
+
+		if !ed25519.Verify(publicKey, signingInput, signature) {
+			return ErrInvalidSignature
+		}
+
+		return nil
+	}
+
+	if len(algorithm) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	hash, ok := hashes[algorithm[2:]]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	hasher := hash.New()
+	hasher.Write(signingInput)
+	digest := hasher.Sum(nil)
+
+	switch algorithm[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("algorithm %s requires a secret key: %w", algorithm, errKeyTypeMismatch)
+		}
+
+		mac := hmac.New(hash.New, secret)
+		mac.Write(signingInput)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidSignature
+		}
+
+	case "RS", "PS":
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s requires an RSA key: %w", algorithm, errKeyTypeMismatch)
+		}
+
+		var err error
+		if algorithm[:2] == "RS" {
+			err = rsa.VerifyPKCS1v15(publicKey, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(publicKey, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+
+		if err != nil {
+			return ErrInvalidSignature
+		}
+
+	case "ES":
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %s requires an EC key: %w", algorithm, errKeyTypeMismatch)
+		}
+
+		// Signature is r and s concatenated, each padded to the curve size
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return ErrInvalidSignature
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(publicKey, digest, r, s) {
+			return ErrInvalidSignature
+		}
+
+	default:
+		return fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	return nil
+}
+
+// Returns the string claim, or empty string if it's missing or not a string
+func (claims Claims) GetString(name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// Returns the claim as a list of strings, a single string is returned as a list of one item
+func (claims Claims) GetStrings(name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+// Checks whether the claim has the given value, a list claim must contain the value
+// Numbers and booleans are compared by their JSON representation
+func (claims Claims) HasValue(name, expected string) bool {
+	switch value := claims[name].(type) {
+	case string:
+		return value == expected
+
+	case json.Number:
+		return value.String() == expected
+
+	case bool:
+		return strconv.FormatBool(value) == expected
+
+	case []any:
+		return slices.Contains(claims.GetStrings(name), expected)
+	}
+
+	return false
+}
+
+// Returns the claim as time, for numeric date claims such as exp
+func (claims Claims) GetTime(name string) (time.Time, bool) {
+	number, ok := claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(seconds), 0), true
+}
+
+// Options of the standard claims validation, empty options are not checked
+type ValidationOptions struct {
+	Issuer   string
+	Audience string
+
+	// Whether the exp claim must be present
+	RequireExpiry bool
+
+	// Allowed clock difference when checking times
+	Leeway time.Duration
+
+	Now time.Time
+}
+
+// Validates the standard claims: iss, aud, exp and nbf
+func (claims Claims) Validate(options ValidationOptions) error {
+	now := options.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	if options.Issuer != "" && claims.GetString("iss") != options.Issuer {
+		return fmt.Errorf("unexpected issuer %q", claims.GetString("iss"))
+	}
+
+	if options.Audience != "" && !slices.Contains(claims.GetStrings("aud"), options.Audience) {
+		return fmt.Errorf("token audience does not contain %q", options.Audience)
+	}
+
+	if expiry, ok := claims.GetTime("exp"); ok {
+		if now.After(expiry.Add(options.Leeway)) {
+			return fmt.Errorf("token expired at %s", expiry.Format(time.RFC3339))
+		}
+	} else if options.RequireExpiry {
+		return fmt.Errorf("token has no expiry")
+	}
+
+	if notBefore, ok := claims.GetTime("nbf"); ok && now.Add(options.Leeway).Before(notBefore) {
+		return fmt.Errorf("token is not valid before %s", notBefore.Format(time.RFC3339))
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestValidateClaims(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	claims := Claims{}
+	_ = decodeSection(
+		base64.RawURLEncoding.EncodeToString([]byte(`{"iss": "issuer", "aud": ["a", "b"], "exp": 1700000100, "nbf": 1699999900}`)),
+		&claims,
+	)
+
+	if err := claims.Validate(ValidationOptions{Issuer: "issuer", Audience: "b", RequireExpiry: true, Now: now}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	invalidOptions := []ValidationOptions{
+		{Issuer: "other", Now: now},
+		{Audience: "c", Now: now},
+		{Now: now.Add(time.Hour)},
+		{Now: now.Add(-time.Hour)},
+	}
+
+	for _, options := range invalidOptions {
+		if err := claims.Validate(options); err == nil {
+			t.Errorf("%+v: expected error, got nil", options)
+		}
+	}
+
+	// Test leeway allows small clock differences
+	if err := claims.Validate(ValidationOptions{Now: now.Add(2 * time.Minute), Leeway: 5 * time.Minute}); err != nil {
+		t.Errorf("unexpected error with leeway: %s", err)
+	}
+
+	if err := (Claims{}).Validate(ValidationOptions{RequireExpiry: true, Now: now}); err == nil {
+		t.Errorf("expected error for missing expiry, got nil")
+	}
+}
@@ -0,0 +1,105 @@
+// Signs tokens for tests, redirector itself only verifies tokens
+package jwttest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/AmrSaber/redirector/src/lib/jwt"
+)
+
+// Signs the claims with the given private key
+// Key is *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey or []byte for HMAC secrets
+func Sign(claims jwt.Claims, algorithm, keyID string, key any) (string, error) {
+	header, err := json.Marshal(jwt.Header{Algorithm: algorithm, KeyID: keyID, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signature, err := sign(algorithm, key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func sign(algorithm string, key any, signingInput []byte) ([]byte, error) {
+	hashes := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+
+	if algorithm == "EdDSA" {
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %s requires an Ed25519 key", algorithm)
+		}
+
+		return ed25519.Sign(privateKey, signingInput), nil
+	}
+
+	hash, ok := hashes[algorithm[min(2, len(algorithm)):]]
+	if len(algorithm) != 5 || !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	hasher := hash.New()
+	hasher.Write(signingInput)
+	digest := hasher.Sum(nil)
+
+	switch algorithm[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %s requires a secret key", algorithm)
+		}
+
+		mac := hmac.New(hash.New, secret)
+		mac.Write(signingInput)
+		return mac.Sum(nil), nil
+
+	case "RS", "PS":
+		privateKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %s requires an RSA key", algorithm)
+		}
+
+		if algorithm[:2] == "RS" {
+			return rsa.SignPKCS1v15(rand.Reader, privateKey, hash, digest)
+		}
+
+		return rsa.SignPSS(rand.Reader, privateKey, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+
+	case "ES":
+		privateKey, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("algorithm %s requires an EC key", algorithm)
+		}
+
+		r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest)
+		if err != nil {
+			return nil, err
+		}
+
+		size := (privateKey.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+
+		return signature, nil
+	}
+
+	return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+}
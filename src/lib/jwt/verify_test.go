@@ -0,0 +1,111 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/AmrSaber/redirector/src/lib/jwt"
+	"github.com/AmrSaber/redirector/src/lib/jwt/jwttest"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	secret := []byte("secret")
+
+	keys := jwt.KeySet{
+		{ID: "rsa", Key: &rsaKey.PublicKey},
+		{ID: "ec", Key: &ecKey.PublicKey},
+		{ID: "ed", Key: edKey.Public()},
+		{ID: "hmac", Key: secret},
+	}
+
+	testCases := []struct {
+		algorithm, keyID string
+		key              any
+	}{
+		{"RS256", "rsa", rsaKey},
+		{"PS384", "rsa", rsaKey},
+		{"ES256", "ec", ecKey},
+		{"EdDSA", "ed", edKey},
+		{"HS512", "hmac", secret},
+		{"HS256", "", secret},
+	}
+
+	for _, testCase := range testCases {
+		raw, err := jwttest.Sign(jwt.Claims{"sub": "alice"}, testCase.algorithm, testCase.keyID, testCase.key)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", testCase.algorithm, err)
+		}
+
+		token, err := jwt.Parse(raw)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", testCase.algorithm, err)
+		}
+
+		if err := token.Verify(keys); err != nil {
+			t.Errorf("%s: unexpected verification error: %s", testCase.algorithm, err)
+		}
+
+		if token.Claims.GetString("sub") != "alice" {
+			t.Errorf("%s: unexpected claims: %v", testCase.algorithm, token.Claims)
+		}
+
+		// Test tampered claims are rejected
+		parts := strings.Split(raw, ".")
+		parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"mallory"}`))
+
+		tampered, _ := jwt.Parse(strings.Join(parts, "."))
+		if err := tampered.Verify(keys); err == nil {
+			t.Errorf("%s: expected tampered token to be rejected", testCase.algorithm)
+		}
+	}
+
+	// Test unsigned tokens and unknown keys are rejected
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{}`)) + "."
+	if token, err := jwt.Parse(unsigned); err != nil || token.Verify(keys) == nil {
+		t.Errorf("expected unsigned token to be rejected")
+	}
+
+	raw, _ := jwttest.Sign(jwt.Claims{}, "RS256", "other", rsaKey)
+	if token, _ := jwt.Parse(raw); token.Verify(jwt.KeySet{{ID: "rsa", Key: &rsaKey.PublicKey}}) != jwt.ErrNoMatchingKey {
+		t.Errorf("expected no matching key error")
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	encode := func(data []byte) string { return base64.RawURLEncoding.EncodeToString(data) }
+
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "1", "alg": "RS256", "use": "sig", "n": %q, "e": "AQAB"},
+		{"kty": "RSA", "kid": "2", "use": "enc", "n": %q, "e": "AQAB"},
+		{"kty": "oct", "kid": "3", "k": %q}
+	]}`, encode(rsaKey.N.Bytes()), encode(rsaKey.N.Bytes()), encode([]byte("secret")))
+
+	keys, err := jwt.ParseJWKS([]byte(jwks))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(keys) != 2 || keys[0].ID != "1" || keys[1].ID != "3" {
+		t.Fatalf("unexpected keys: %+v", keys)
+	}
+
+	raw, _ := jwttest.Sign(jwt.Claims{}, "RS256", "1", rsaKey)
+	if token, _ := jwt.Parse(raw); token.Verify(keys) != nil {
+		t.Errorf("expected token to be verified with the parsed key")
+	}
+
+	if _, err := jwt.ParseJWKS([]byte(`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`)); err == nil {
+		t.Errorf("expected error for invalid EC point, got nil")
+	}
+}
@@ -0,0 +1,228 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	ACCESS_LOG_FORMAT_JSON     = "json"
+	ACCESS_LOG_FORMAT_COMBINED = "combined"
+	ACCESS_LOG_FORMAT_TEMPLATE = "template"
+)
+
+const (
+	ACCESS_LOG_OUTPUT_STDOUT = "stdout"
+	ACCESS_LOG_OUTPUT_STDERR = "stderr"
+)
+
+// A single handled request
+type AccessEntry struct {
+	Time      time.Time `json:"time"`
+	ClientIP  string    `json:"client_ip"`
+	User      string    `json:"user,omitempty"`
+	Method    string    `json:"method"`
+	Host      string    `json:"host"`
+	Path      string    `json:"path"`
+	Query     string    `json:"query,omitempty"`
+	Protocol  string    `json:"protocol"`
+	RuleIndex *int      `json:"rule_index,omitempty"`
+	RuleName  string    `json:"rule,omitempty"`
+	Status    int       `json:"status"`
+	Target    string    `json:"target,omitempty"`
+	Bytes     int       `json:"bytes"`
+	LatencyMs float64   `json:"latency_ms"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Referer   string    `json:"referer,omitempty"`
+}
+
+type AccessLogOptions struct {
+	// One of json, combined or template
+	Format string `yaml:"format"`
+
+	// Go template for each log line, used with template format
+	Template string `yaml:"template,omitempty"`
+
+	// One of stdout, stderr or a file path
+	Output string `yaml:"output"`
+}
+
+// An opened access log, see OpenAccessLog
+type AccessLog struct {
+	options  AccessLogOptions
+	template *template.Template
+	output   io.Writer
+	file     *os.File
+}
+
+var (
+	access     *AccessLog
+	accessLock sync.Mutex
+)
+
+// Validates the access log template
+func ParseAccessLogTemplate(text string) (*template.Template, error) {
+	return template.New("access-log").Parse(text)
+}
+
+// Sets up the access log, a nil options disables it
+// Nothing is changed if the options are the same as the current ones
+func ConfigureAccessLog(options *AccessLogOptions) error {
+	accessLog, err := OpenAccessLog(options)
+	if err != nil {
+		return err
+	}
+
+	UseAccessLog(accessLog)
+	return nil
+}
+
+// Opens the access log of the given options without using it, so that its errors can be handled before anything is changed
+// Returns the current access log if the options are the same as its ones, and nil for nil options
+func OpenAccessLog(options *AccessLogOptions) (*AccessLog, error) {
+	if options == nil {
+		return nil, nil
+	}
+
+	accessLock.Lock()
+	current := access
+	accessLock.Unlock()
+
+	if current != nil && current.options == *options {
+		return current, nil
+	}
+
+	accessLog := &AccessLog{options: *options}
+
+	if options.Format == ACCESS_LOG_FORMAT_TEMPLATE {
+		accessTemplate, err := ParseAccessLogTemplate(options.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access log template: %w", err)
+		}
+
+		accessLog.template = accessTemplate
+	}
+
+	switch options.Output {
+	case "", ACCESS_LOG_OUTPUT_STDOUT:
+		accessLog.output = os.Stdout
+	case ACCESS_LOG_OUTPUT_STDERR:
+		accessLog.output = os.Stderr
+	default:
+		file, err := os.OpenFile(options.Output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("could not open access log file: %w", err)
+		}
+
+		accessLog.output = file
+		accessLog.file = file
+	}
+
+	return accessLog, nil
+}
+
+// Replaces the current access log with the given one, closing the current one, nil disables the access log
+func UseAccessLog(accessLog *AccessLog) {
+	accessLock.Lock()
+	defer accessLock.Unlock()
+
+	if access == accessLog {
+		return
+	}
+
+	closeAccessLogUnsafe()
+	access = accessLog
+}
+
+func IsAccessLogEnabled() bool {
+	accessLock.Lock()
+	defer accessLock.Unlock()
+
+	return access != nil
+}
+
+// Writes the entry to the access log if it's enabled
+func LogAccess(entry AccessEntry) {
+	accessLock.Lock()
+	defer accessLock.Unlock()
+
+	if access == nil {
+		return
+	}
+
+	line, err := access.format(entry)
+	if err != nil {
+		Errorf("could not format access log entry: %s", err)
+		return
+	}
+
+	if _, err := io.WriteString(access.output, line+"\n"); err != nil {
+		Errorf("could not write access log entry: %s", err)
+	}
+}
+
+func closeAccessLogUnsafe() {
+	if access != nil && access.file != nil {
+		_ = access.file.Close()
+	}
+}
+
+func (logger *AccessLog) format(entry AccessEntry) (string, error) {
+	switch logger.options.Format {
+	case ACCESS_LOG_FORMAT_COMBINED:
+		return formatCombined(entry), nil
+
+	case ACCESS_LOG_FORMAT_TEMPLATE:
+		var out strings.Builder
+		err := logger.template.Execute(&out, entry)
+		return out.String(), err
+
+	default:
+		out, err := json.Marshal(entry)
+		return string(out), err
+	}
+}
+
+// Formats the entry in Apache Combined Log Format
+func formatCombined(entry AccessEntry) string {
+	requestLine := entry.Method + " " + entry.Path
+	if entry.Query != "" {
+		requestLine += "?" + entry.Query
+	}
+	requestLine += " " + entry.Protocol
+
+	bytes := "-"
+	if entry.Bytes > 0 {
+		bytes = fmt.Sprint(entry.Bytes)
+	}
+
+	return fmt.Sprintf(
+		`%s - %s [%s] "%s" %d %s "%s" "%s"`,
+		entry.ClientIP,
+		orDash(entry.User),
+		entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		escapeCombined(requestLine),
+		entry.Status,
+		bytes,
+		escapeCombined(orDash(entry.Referer)),
+		escapeCombined(orDash(entry.UserAgent)),
+	)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func escapeCombined(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogFormats(t *testing.T) {
+	ruleIndex := 2
+	entry := AccessEntry{
+		Time:      time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC),
+		ClientIP:  "10.0.0.1",
+		Method:    "GET",
+		Host:      "go.example.com",
+		Path:      "/docs",
+		Query:     "a=1",
+		Protocol:  "HTTP/1.1",
+		RuleIndex: &ruleIndex,
+		RuleName:  "docs",
+		Status:    307,
+		Target:    "https://docs.example.com",
+		Bytes:     57,
+		UserAgent: `curl/8.0 "test"`,
+	}
+
+	logPath := path.Join(t.TempDir(), "access.log")
+	readLastLine := func() string {
+		out, _ := os.ReadFile(logPath)
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		return lines[len(lines)-1]
+	}
+
+	defer ConfigureAccessLog(nil)
+
+	// Test JSON
+	if err := ConfigureAccessLog(&AccessLogOptions{Format: ACCESS_LOG_FORMAT_JSON, Output: logPath}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	LogAccess(entry)
+
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(readLastLine()), &parsed); err != nil {
+		t.Fatalf("could not parse json line: %s", err)
+	}
+
+	if parsed["rule_index"] != float64(2) || parsed["target"] != entry.Target || parsed["status"] != float64(307) {
+		t.Errorf("unexpected json entry: %v", parsed)
+	}
+
+	// Test combined
+	if err := ConfigureAccessLog(&AccessLogOptions{Format: ACCESS_LOG_FORMAT_COMBINED, Output: logPath}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	LogAccess(entry)
+
+	expected := `10.0.0.1 - - [05/Mar/2024:10:20:30 +0000] "GET /docs?a=1 HTTP/1.1" 307 57 "-" "curl/8.0 \"test\""`
+	if got := readLastLine(); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+
+	// Test template
+	options := &AccessLogOptions{Format: ACCESS_LOG_FORMAT_TEMPLATE, Template: "{{.Host}}{{.Path}} -> {{.Target}} ({{.RuleName}})", Output: logPath}
+	if err := ConfigureAccessLog(options); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	LogAccess(entry)
+
+	expected = "go.example.com/docs -> https://docs.example.com (docs)"
+	if got := readLastLine(); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
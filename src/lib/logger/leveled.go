@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Leveled logging on top of slog, debug and info go to STDOUT while warn and error go to STDERR
+
+var (
+	level = new(slog.LevelVar)
+
+	leveled = slog.New(&splitHandler{
+		std: slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}),
+		err: slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}),
+	})
+
+	// Level given on the command line takes precedence over the config
+	isFlagLevelSet bool
+	levelLock      sync.Mutex
+
+	// Last level applied from the config, so that reloads only apply it when it changes
+	configLevel      string
+	isConfigLevelSet bool
+)
+
+var levelNames = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+func Debugf(format string, args ...any) { logf(slog.LevelDebug, format, args...) }
+func Infof(format string, args ...any)  { logf(slog.LevelInfo, format, args...) }
+func Warnf(format string, args ...any)  { logf(slog.LevelWarn, format, args...) }
+func Errorf(format string, args ...any) { logf(slog.LevelError, format, args...) }
+
+func logf(logLevel slog.Level, format string, args ...any) {
+	ctx := context.Background()
+	if !leveled.Enabled(ctx, logLevel) {
+		return
+	}
+
+	leveled.Log(ctx, logLevel, strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+// Validates the level name, one of (debug, info, warn, error)
+func ParseLevel(name string) (slog.Level, error) {
+	logLevel, ok := levelNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("invalid log level %q, must be one of (debug, info, warn, error)", name)
+	}
+
+	return logLevel, nil
+}
+
+// Returns the name of the current level
+func GetLevel() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// Sets the level at runtime
+func SetLevel(name string) error {
+	logLevel, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+
+	level.Set(logLevel)
+	return nil
+}
+
+// Sets the level given on the command line, the config level will be ignored after that
+func SetFlagLevel(name string) error {
+	levelLock.Lock()
+	defer levelLock.Unlock()
+
+	if err := SetLevel(name); err != nil {
+		return err
+	}
+
+	isFlagLevelSet = true
+	return nil
+}
+
+// Sets the level from the config, unless the level was given on the command line
+// The level is only applied when it differs from the last config level, so that a level set at runtime survives reloads
+// An empty level resets to info
+func SetConfigLevel(name string) error {
+	levelLock.Lock()
+	defer levelLock.Unlock()
+
+	if isFlagLevelSet || (isConfigLevelSet && strings.EqualFold(configLevel, name)) {
+		return nil
+	}
+
+	configLevel, isConfigLevelSet = name, true
+
+	if name == "" {
+		level.Set(slog.LevelInfo)
+		return nil
+	}
+
+	return SetLevel(name)
+}
+
+type splitHandler struct {
+	std slog.Handler
+	err slog.Handler
+}
+
+func (h *splitHandler) Enabled(ctx context.Context, logLevel slog.Level) bool {
+	return logLevel >= level.Level()
+}
+
+func (h *splitHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		return h.err.Handle(ctx, record)
+	}
+
+	return h.std.Handle(ctx, record)
+}
+
+func (h *splitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &splitHandler{std: h.std.WithAttrs(attrs), err: h.err.WithAttrs(attrs)}
+}
+
+func (h *splitHandler) WithGroup(name string) slog.Handler {
+	return &splitHandler{std: h.std.WithGroup(name), err: h.err.WithGroup(name)}
+}
@@ -0,0 +1,50 @@
+package logger
+
+import "testing"
+
+func TestLevelPrecedence(t *testing.T) {
+	defer func() {
+		isFlagLevelSet, isConfigLevelSet = false, false
+		SetLevel("info")
+	}()
+
+	if err := SetConfigLevel("debug"); err != nil || GetLevel() != "debug" {
+		t.Errorf("expected debug level from config, got %q (error: %v)", GetLevel(), err)
+	}
+
+	// Removing level from config resets it
+	if err := SetConfigLevel(""); err != nil || GetLevel() != "info" {
+		t.Errorf("expected info level, got %q (error: %v)", GetLevel(), err)
+	}
+
+	// Runtime level is kept on reloads until the config level changes
+	if err := SetLevel("error"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := SetConfigLevel(""); err != nil || GetLevel() != "error" {
+		t.Errorf("expected runtime error level to be kept, got %q (error: %v)", GetLevel(), err)
+	}
+
+	if err := SetConfigLevel("debug"); err != nil || GetLevel() != "debug" {
+		t.Errorf("expected changed debug level from config, got %q (error: %v)", GetLevel(), err)
+	}
+
+	if err := SetFlagLevel("warn"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Config level is ignored after flag level is set
+	if err := SetConfigLevel("debug"); err != nil || GetLevel() != "warn" {
+		t.Errorf("expected warn level from flag, got %q (error: %v)", GetLevel(), err)
+	}
+
+	// Runtime changes are always applied
+	if err := SetLevel("error"); err != nil || GetLevel() != "error" {
+		t.Errorf("expected error level, got %q (error: %v)", GetLevel(), err)
+	}
+
+	if err := SetLevel("verbose"); err == nil {
+		t.Errorf("expected error on invalid level, got nil")
+	}
+}
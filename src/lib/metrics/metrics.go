@@ -0,0 +1,280 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Minimal implementation of prometheus metrics, exposed in prometheus text format
+
+type collector interface {
+	getName() string
+	write(w io.Writer)
+}
+
+var (
+	registry     = make(map[string]collector)
+	registryLock sync.Mutex
+)
+
+// Registers the collector, replacing any collector with the same name
+func register(c collector) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	registry[c.getName()] = c
+}
+
+// Returns a handler that writes all the registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(res)
+	})
+}
+
+// Writes all the registered metrics sorted by name
+func Write(w io.Writer) {
+	registryLock.Lock()
+	collectors := make([]collector, 0, len(registry))
+	for _, c := range registry {
+		collectors = append(collectors, c)
+	}
+	registryLock.Unlock()
+
+	slices.SortFunc(collectors, func(a, b collector) int { return strings.Compare(a.getName(), b.getName()) })
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+type metric struct {
+	name       string
+	help       string
+	labelNames []string
+}
+
+func (m metric) getName() string {
+	return m.name
+}
+
+func (m metric) writeHeader(w io.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, metricType)
+}
+
+// Formats the labels as {name="value",...}, extra label pairs are appended at the end
+func (m metric) formatLabels(labelValues []string, extra ...string) string {
+	pairs := make([]string, 0, len(labelValues)+len(extra)/2)
+
+	for i, value := range labelValues {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, m.labelNames[i], labelValueEscaper.Replace(value)))
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], labelValueEscaper.Replace(extra[i+1])))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (m metric) checkLabels(labelValues []string) {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("metric %q expects %d label values, got %d", m.name, len(m.labelNames), len(labelValues)))
+	}
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Counter with optional labels
+type Counter struct {
+	metric
+
+	lock   sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	counter := &Counter{
+		metric: metric{name: name, help: help, labelNames: labelNames},
+		values: make(map[string]*counterValue),
+	}
+
+	// Counters without labels are exposed with 0 from the start
+	if len(labelNames) == 0 {
+		counter.values[""] = &counterValue{}
+	}
+
+	register(counter)
+	return counter
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	c.checkLabels(labelValues)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	if _, ok := c.values[key]; !ok {
+		c.values[key] = &counterValue{labelValues: slices.Clone(labelValues)}
+	}
+
+	c.values[key].value += delta
+}
+
+// Returns the current value for the given labels
+func (c *Counter) Get(labelValues ...string) float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if value, ok := c.values[strings.Join(labelValues, "\xff")]; ok {
+		return value.value
+	}
+
+	return 0
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.writeHeader(w, "counter")
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, key := range sortedKeys(c.values) {
+		value := c.values[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.formatLabels(value.labelValues), formatFloat(value.value))
+	}
+}
+
+// Gauge whose value is computed when metrics are collected
+type GaugeFunc struct {
+	metric
+	valueFunc func() float64
+}
+
+func NewGaugeFunc(name, help string, valueFunc func() float64) *GaugeFunc {
+	gauge := &GaugeFunc{metric: metric{name: name, help: help}, valueFunc: valueFunc}
+
+	register(gauge)
+	return gauge
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.writeHeader(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.valueFunc()))
+}
+
+// Histogram with optional labels
+type Histogram struct {
+	metric
+	buckets []float64
+
+	lock   sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labelValues  []string
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	histogram := &Histogram{
+		metric:  metric{name: name, help: help, labelNames: labelNames},
+		buckets: slices.Clone(buckets),
+		values:  make(map[string]*histogramValue),
+	}
+
+	slices.Sort(histogram.buckets)
+
+	register(histogram)
+	return histogram
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.checkLabels(labelValues)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	if _, ok := h.values[key]; !ok {
+		h.values[key] = &histogramValue{
+			labelValues:  slices.Clone(labelValues),
+			bucketCounts: make([]uint64, len(h.buckets)),
+		}
+	}
+
+	histValue := h.values[key]
+	histValue.count++
+	histValue.sum += value
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			histValue.bucketCounts[i]++
+		}
+	}
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.writeHeader(w, "histogram")
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for _, key := range sortedKeys(h.values) {
+		value := h.values[key]
+
+		for i, bound := range h.buckets {
+			labels := h.formatLabels(value.labelValues, "le", formatFloat(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels, value.bucketCounts[i])
+		}
+
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.formatLabels(value.labelValues, "le", "+Inf"), value.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.formatLabels(value.labelValues), formatFloat(value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.formatLabels(value.labelValues), value.count)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMetricsFormat(t *testing.T) {
+	counter := NewCounter("test_requests_total", "Test requests", "rule")
+	counter.Inc(`a.com/"x"`)
+	counter.Add(2, "b.com")
+
+	histogram := NewHistogram("test_duration_seconds", "Test durations", []float64{1, 0.1})
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+
+	NewGaugeFunc("test_age_seconds", "Test age", func() float64 { return 42 })
+
+	var out bytes.Buffer
+	Write(&out)
+
+	expectedLines := []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{rule="a.com/\"x\""} 1`,
+		`test_requests_total{rule="b.com"} 2`,
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{le="0.1"} 1`,
+		`test_duration_seconds_bucket{le="1"} 2`,
+		`test_duration_seconds_bucket{le="+Inf"} 2`,
+		"test_duration_seconds_sum 0.55",
+		"test_duration_seconds_count 2",
+		"# TYPE test_age_seconds gauge",
+		"test_age_seconds 42",
+	}
+
+	for _, line := range expectedLines {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("expected output to contain %q, got:\n%s", line, out.String())
+		}
+	}
+}
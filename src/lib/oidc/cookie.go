@@ -0,0 +1,109 @@
+package oidc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Encodes the payload as JSON and signs it, so that it can be kept in a cookie
+// Payload is readable by the client, so it must not contain secrets
+func SignValue(secret []byte, payload any) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(computeMac(secret, encoded)), nil
+}
+
+// Verifies the signature of a value created with SignValue, and decodes its payload into target
+func VerifyValue(secret []byte, value string, target any) error {
+	encoded, encodedMac, found := strings.Cut(value, ".")
+	if !found {
+		return fmt.Errorf("malformed signed value")
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMac)
+	if err != nil || !hmac.Equal(mac, computeMac(secret, encoded)) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("malformed signed value")
+	}
+
+	return json.Unmarshal(data, target)
+}
+
+func computeMac(secret []byte, value string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(value))
+
+	return mac.Sum(nil)
+}
+
+// Encodes the payload as JSON and encrypts it, so that it can be kept in a cookie that the client can neither read nor change
+func SealValue(secret []byte, payload any) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, data, nil)), nil
+}
+
+// Decrypts a value created with SealValue, and decodes its payload into target
+func OpenValue(secret []byte, value string, target any) error {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("malformed sealed value")
+	}
+
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return fmt.Errorf("malformed sealed value")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return fmt.Errorf("invalid sealed value")
+	}
+
+	return json.Unmarshal(data, target)
+}
+
+// AES-256-GCM with a key derived from the secret
+func newAEAD(secret []byte) (cipher.AEAD, error) {
+	key := sha256.Sum256(secret)
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,289 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/lib/jwt"
+)
+
+// Minimal OpenID Connect relying party, using the authorization code flow with PKCE
+
+// Keys are fetched again for unknown key IDs, at most once per this duration
+const keysRefreshInterval = time.Minute
+
+// Allowed clock difference with the provider
+const clockLeeway = time.Minute
+
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type Provider struct {
+	issuer string
+	client *http.Client
+
+	lock          sync.Mutex
+	metadata      *Metadata
+	keys          jwt.KeySet
+	keysFetchedAt time.Time
+}
+
+type AuthParams struct {
+	ClientID     string
+	RedirectURI  string
+	Scopes       []string
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+type ExchangeParams struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Code         string
+	CodeVerifier string
+}
+
+func NewProvider(issuer string, client *http.Client) *Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Provider{issuer: strings.TrimSuffix(issuer, "/"), client: client}
+}
+
+// Returns the provider metadata, fetched from the discovery endpoint on first use
+func (provider *Provider) GetMetadata(ctx context.Context) (*Metadata, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+
+	if provider.metadata != nil {
+		return provider.metadata, nil
+	}
+
+	var metadata Metadata
+	if err := provider.getJson(ctx, provider.issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("could not discover provider %s: %w", provider.issuer, err)
+	}
+
+	if strings.TrimSuffix(metadata.Issuer, "/") != provider.issuer {
+		return nil, fmt.Errorf("provider issuer %q does not match configured issuer %q", metadata.Issuer, provider.issuer)
+	}
+
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JwksURI == "" {
+		return nil, fmt.Errorf("provider %s metadata is missing required endpoints", provider.issuer)
+	}
+
+	provider.metadata = &metadata
+	return provider.metadata, nil
+}
+
+// Returns the URL of the provider login page
+func (provider *Provider) AuthCodeURL(ctx context.Context, params AuthParams) (string, error) {
+	metadata, err := provider.GetMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(params.CodeVerifier))
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", params.ClientID)
+	query.Set("redirect_uri", params.RedirectURI)
+	query.Set("scope", strings.Join(params.Scopes, " "))
+	query.Set("state", params.State)
+	query.Set("nonce", params.Nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchanges the authorization code for tokens, and returns the raw ID token
+func (provider *Provider) Exchange(ctx context.Context, params ExchangeParams) (string, error) {
+	metadata, err := provider.GetMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", params.Code)
+	form.Set("redirect_uri", params.RedirectURI)
+	form.Set("client_id", params.ClientID)
+	form.Set("code_verifier", params.CodeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if params.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(params.ClientID), url.QueryEscape(params.ClientSecret))
+	}
+
+	res, err := provider.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("could not call token endpoint: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("could not read token response: %w", err)
+	}
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal(body, &tokens)
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint responded with %d: %s %s", res.StatusCode, tokens.Error, tokens.ErrorDescription)
+	}
+
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("token response has no ID token")
+	}
+
+	return tokens.IDToken, nil
+}
+
+// Verifies the ID token signature and claims, and returns its claims
+func (provider *Provider) VerifyIDToken(ctx context.Context, rawIDToken, clientID, nonce string) (jwt.Claims, error) {
+	metadata, err := provider.GetMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := provider.getKeys(ctx, metadata, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = token.Verify(keys)
+	if err == jwt.ErrNoMatchingKey {
+		// Provider could have rotated its keys
+		if keys, err = provider.getKeys(ctx, metadata, true); err != nil {
+			return nil, err
+		}
+
+		err = token.Verify(keys)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	options := jwt.ValidationOptions{Issuer: metadata.Issuer, Audience: clientID, RequireExpiry: true, Leeway: clockLeeway}
+	if err := token.Claims.Validate(options); err != nil {
+		return nil, err
+	}
+
+	if token.Claims.GetString("nonce") != nonce {
+		return nil, fmt.Errorf("ID token nonce does not match")
+	}
+
+	if token.Claims.GetString("sub") == "" {
+		return nil, fmt.Errorf("ID token has no subject")
+	}
+
+	return token.Claims, nil
+}
+
+func (provider *Provider) getKeys(ctx context.Context, metadata *Metadata, refresh bool) (jwt.KeySet, error) {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+
+	if provider.keys != nil && (!refresh || time.Since(provider.keysFetchedAt) < keysRefreshInterval) {
+		return provider.keys, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.JwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := provider.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch provider keys: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not fetch provider keys: status %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("could not read provider keys: %w", err)
+	}
+
+	keys, err := jwt.ParseJWKS(body)
+	if err != nil {
+		return nil, err
+	}
+
+	provider.keys = keys
+	provider.keysFetchedAt = time.Now()
+
+	return keys, nil
+}
+
+func (provider *Provider) getJson(ctx context.Context, url string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := provider.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(target)
+}
+
+// Returns a random URL-safe string, used for state, nonce and PKCE verifier
+func RandomString() string {
+	data := make([]byte, 32)
+	_, _ = rand.Read(data)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
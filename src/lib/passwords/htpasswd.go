@@ -0,0 +1,61 @@
+package passwords
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+type HtpasswdEntry struct {
+	Username string
+	Hash     string
+}
+
+// Reads the users of an Apache htpasswd file
+func ReadHtpasswdFile(filePath string) ([]HtpasswdEntry, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseHtpasswd(file)
+}
+
+// Parses htpasswd lines in the format <username>:<hash>, empty lines and lines starting with # are skipped
+// Only hashes supported by Compare are accepted: the default MD5 of htpasswd, -B (bcrypt) or -5/-6 (SHA-crypt) on newer versions
+func ParseHtpasswd(reader io.Reader) ([]HtpasswdEntry, error) {
+	entries := make([]HtpasswdEntry, 0)
+	scanner := bufio.NewScanner(reader)
+
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		username, hashed, found := strings.Cut(line, ":")
+		if !found || username == "" || hashed == "" {
+			return nil, fmt.Errorf("line %d: expected <username>:<hash>", lineNumber)
+		}
+
+		if algorithm := GetAlgorithm(hashed); algorithm == "" || !slices.Contains(SupportedAlgorithms, algorithm) {
+			return nil, fmt.Errorf("line %d: unsupported hash for user %q, supported algorithms are: %s", lineNumber, username, strings.Join(SupportedAlgorithms, ", "))
+		}
+
+		if err := ValidatePassword(hashed); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		entries = append(entries, HtpasswdEntry{Username: username, Hash: hashed})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,97 @@
+package passwords
+
+import (
+	"crypto/md5"
+	"fmt"
+	"strings"
+)
+
+// Implementation of Apache's MD5-crypt variant ($apr1$ hashes), the default of the htpasswd tool
+// Only used to check existing hashes, it's too weak to be offered for new ones
+
+const (
+	apr1Prefix        = "$apr1$"
+	apr1Rounds        = 1000
+	apr1MaxSaltLength = 8
+)
+
+// Parses a hash in the format $apr1$<salt>$<hash>, and returns its salt
+func parseApr1(hashed string) (string, error) {
+	salt, hash, found := strings.Cut(strings.TrimPrefix(hashed, apr1Prefix), "$")
+	if !found || len(salt) > apr1MaxSaltLength || len(hash) != 22 {
+		return "", fmt.Errorf("invalid apr1 hash: expected salt (up to %d characters) and hash", apr1MaxSaltLength)
+	}
+
+	return salt, nil
+}
+
+// Returns the full apr1 hash of the password
+func apr1Crypt(password, salt string) string {
+	key := []byte(password)
+
+	// Alternate digest: key, salt, key
+	digest := md5.New()
+	digest.Write(key)
+	digest.Write([]byte(salt))
+	digest.Write(key)
+	alternate := digest.Sum(nil)
+
+	// Initial digest: key, prefix, salt, the alternate digest repeated to the key length, then a NUL or the first key byte for each bit of the key length
+	digest = md5.New()
+	digest.Write(key)
+	digest.Write([]byte(apr1Prefix + salt))
+	digest.Write(repeatBytes(alternate, len(key)))
+
+	for length := len(key); length > 0; length >>= 1 {
+		if length&1 != 0 {
+			digest.Write([]byte{0})
+		} else {
+			digest.Write(key[:1])
+		}
+	}
+
+	result := digest.Sum(nil)
+	for i := 0; i < apr1Rounds; i++ {
+		digest = md5.New()
+
+		if i%2 != 0 {
+			digest.Write(key)
+		} else {
+			digest.Write(result)
+		}
+
+		if i%3 != 0 {
+			digest.Write([]byte(salt))
+		}
+
+		if i%7 != 0 {
+			digest.Write(key)
+		}
+
+		if i%2 != 0 {
+			digest.Write(result)
+		} else {
+			digest.Write(key)
+		}
+
+		result = digest.Sum(nil)
+	}
+
+	var out strings.Builder
+	out.WriteString(apr1Prefix + salt + "$")
+
+	write := func(value uint, count int) {
+		for i := 0; i < count; i++ {
+			out.WriteByte(shaCryptAlphabet[value&0x3f])
+			value >>= 6
+		}
+	}
+
+	for _, group := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		write(uint(result[group[0]])<<16|uint(result[group[1]])<<8|uint(result[group[2]]), 4)
+	}
+
+	write(uint(result[11]), 2)
+
+	return out.String()
+}
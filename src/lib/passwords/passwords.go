@@ -0,0 +1,251 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing and verification, the algorithm of a hash is detected by its prefix
+// Values without a known prefix are treated as plaintext passwords
+
+const (
+	ALGORITHM_BCRYPT       = "bcrypt"
+	ALGORITHM_ARGON2ID     = "argon2id"
+	ALGORITHM_SHA256_CRYPT = "sha256-crypt"
+	ALGORITHM_SHA512_CRYPT = "sha512-crypt"
+
+	// Unsalted and fast, only accepted for long random values such as API keys, see HashKey
+	ALGORITHM_SHA256 = "sha256"
+
+	// Apache MD5-crypt, only supported for existing hashes such as the ones in htpasswd files
+	ALGORITHM_APR1 = "apr1"
+)
+
+// Algorithms that new password hashes can be created with
+var Algorithms = []string{ALGORITHM_BCRYPT, ALGORITHM_ARGON2ID, ALGORITHM_SHA256_CRYPT, ALGORITHM_SHA512_CRYPT}
+
+// Algorithms of the password hashes that can be checked, including the ones that are too weak for new hashes
+var SupportedAlgorithms = append(slices.Clone(Algorithms), ALGORITHM_APR1)
+
+const sha256Prefix = "sha256:"
+
+// Argon2id parameters used for new hashes, as recommended by OWASP
+const (
+	argon2Parallelism = 1
+	argon2SaltLength  = 16
+	argon2KeyLength   = 32
+)
+
+// Costs used for new hashes, variables so that tests can lower them
+var (
+	bcryptCost              = bcrypt.DefaultCost
+	argon2Memory     uint32 = 19 * 1024
+	argon2Iterations uint32 = 2
+	shaCryptRounds          = shaCryptDefaultRounds
+)
+
+// Returns the algorithm of the given hash, or empty string if it's not a known hash
+func GetAlgorithm(hashed string) string {
+	switch {
+	case strings.HasPrefix(hashed, "$2a$"), strings.HasPrefix(hashed, "$2b$"), strings.HasPrefix(hashed, "$2y$"):
+		return ALGORITHM_BCRYPT
+	case strings.HasPrefix(hashed, "$argon2id$"):
+		return ALGORITHM_ARGON2ID
+	case strings.HasPrefix(hashed, sha256CryptPrefix):
+		return ALGORITHM_SHA256_CRYPT
+	case strings.HasPrefix(hashed, sha512CryptPrefix):
+		return ALGORITHM_SHA512_CRYPT
+	case strings.HasPrefix(hashed, sha256Prefix):
+		return ALGORITHM_SHA256
+	case strings.HasPrefix(hashed, apr1Prefix):
+		return ALGORITHM_APR1
+	}
+
+	return ""
+}
+
+// Validates the format of the given hash, plaintext passwords are always valid
+func Validate(hashed string) error {
+	switch GetAlgorithm(hashed) {
+	case ALGORITHM_BCRYPT:
+		if _, err := bcrypt.Cost([]byte(hashed)); err != nil {
+			return fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+
+	case ALGORITHM_ARGON2ID:
+		if _, err := parseArgon2id(hashed); err != nil {
+			return err
+		}
+
+	case ALGORITHM_SHA256_CRYPT, ALGORITHM_SHA512_CRYPT:
+		if _, err := parseShaCrypt(hashed); err != nil {
+			return err
+		}
+
+	case ALGORITHM_APR1:
+		if _, err := parseApr1(hashed); err != nil {
+			return err
+		}
+
+	case ALGORITHM_SHA256:
+		if digest, err := hex.DecodeString(strings.TrimPrefix(hashed, sha256Prefix)); err != nil || len(digest) != sha256.Size {
+			return fmt.Errorf("invalid sha256 hash: expected %d hex characters", 2*sha256.Size)
+		}
+	}
+
+	return nil
+}
+
+// Validates the format of a password hash, rejecting the fast hashes that are only meant for keys
+func ValidatePassword(hashed string) error {
+	if GetAlgorithm(hashed) == ALGORITHM_SHA256 {
+		return fmt.Errorf("sha256 hashes are too fast for passwords, use one of: %s", strings.Join(SupportedAlgorithms, ", "))
+	}
+
+	return Validate(hashed)
+}
+
+// Checks whether the password matches the given hash or plaintext password
+func Compare(hashed, password string) bool {
+	switch GetAlgorithm(hashed) {
+	case ALGORITHM_BCRYPT:
+		return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) == nil
+
+	case ALGORITHM_ARGON2ID:
+		params, err := parseArgon2id(hashed)
+		if err != nil {
+			return false
+		}
+
+		key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+		return subtle.ConstantTimeCompare(key, params.key) == 1
+
+	case ALGORITHM_SHA256_CRYPT, ALGORITHM_SHA512_CRYPT:
+		params, err := parseShaCrypt(hashed)
+		if err != nil {
+			return false
+		}
+
+		return subtle.ConstantTimeCompare([]byte(params.crypt(password)), []byte(hashed)) == 1
+
+	case ALGORITHM_APR1:
+		salt, err := parseApr1(hashed)
+		if err != nil {
+			return false
+		}
+
+		return subtle.ConstantTimeCompare([]byte(apr1Crypt(password, salt)), []byte(hashed)) == 1
+
+	case ALGORITHM_SHA256:
+		passwordHash := sha256.Sum256([]byte(password))
+		expectedPasswordHash, _ := hex.DecodeString(strings.ToLower(strings.TrimPrefix(hashed, sha256Prefix)))
+
+		return subtle.ConstantTimeCompare(passwordHash[:], expectedPasswordHash) == 1
+	}
+
+	// Hashes are used to perform const-time password check
+	passwordHash := sha256.Sum256([]byte(password))
+	expectedPasswordHash := sha256.Sum256([]byte(hashed))
+
+	return subtle.ConstantTimeCompare(passwordHash[:], expectedPasswordHash[:]) == 1
+}
+
+// Hashes the password with the given algorithm and a random salt
+func Hash(password, algorithm string) (string, error) {
+	switch algorithm {
+	case ALGORITHM_BCRYPT:
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+		return string(hashed), err
+
+	case ALGORITHM_ARGON2ID:
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		key := argon2.IDKey([]byte(password), salt, argon2Iterations, argon2Memory, argon2Parallelism, argon2KeyLength)
+
+		return fmt.Sprintf(
+			"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version,
+			argon2Memory,
+			argon2Iterations,
+			argon2Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+
+	case ALGORITHM_SHA256_CRYPT, ALGORITHM_SHA512_CRYPT:
+		salt, err := randomShaCryptSalt()
+		if err != nil {
+			return "", err
+		}
+
+		prefix := sha256CryptPrefix
+		if algorithm == ALGORITHM_SHA512_CRYPT {
+			prefix = sha512CryptPrefix
+		}
+
+		params := shaCryptParams{prefix: prefix, salt: salt, rounds: shaCryptRounds, explicitRounds: shaCryptRounds != shaCryptDefaultRounds}
+		return params.crypt(password), nil
+	}
+
+	return "", fmt.Errorf("unknown algorithm %q, must be one of: %s", algorithm, strings.Join(Algorithms, ", "))
+}
+
+// Hashes a key with unsalted sha256, only meant for long random values such as API keys
+func HashKey(key string) string {
+	digest := sha256.Sum256([]byte(key))
+	return sha256Prefix + hex.EncodeToString(digest[:])
+}
+
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// Parses a hash in the PHC string format, e.g. $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>
+func parseArgon2id(hashed string) (argon2idParams, error) {
+	var params argon2idParams
+
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 {
+		return params, fmt.Errorf("invalid argon2id hash: expected 5 sections, found %d", len(parts)-1)
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, fmt.Errorf("invalid argon2id hash: unsupported version %q", parts[2])
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return params, fmt.Errorf("invalid argon2id hash parameters %q: %w", parts[3], err)
+	}
+
+	if params.iterations == 0 || params.parallelism == 0 {
+		return params, fmt.Errorf("invalid argon2id hash parameters %q", parts[3])
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, fmt.Errorf("invalid argon2id hash salt: %w", err)
+	}
+
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return params, fmt.Errorf("invalid argon2id hash key")
+	}
+
+	return params, nil
+}
@@ -0,0 +1,168 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestShaCryptVectors(t *testing.T) {
+	// Generated with openssl passwd and glibc crypt
+	hashes := []string{
+		"$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+		"$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA",
+		"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.",
+	}
+
+	for _, hashed := range hashes {
+		if !Compare(hashed, "Hello world!") {
+			t.Errorf("expected password to match %s", hashed)
+		}
+
+		if Compare(hashed, "Hello world") {
+			t.Errorf("expected wrong password not to match %s", hashed)
+		}
+	}
+}
+
+func TestApr1Vectors(t *testing.T) {
+	// Generated with openssl passwd -apr1
+	vectors := map[string]string{
+		"$apr1$saltstri$aGfuB7Lcvs2TUeFTqUVfN0": "Hello world!",
+		"$apr1$abc$BfqKdn9xFDWJPa3kcp/PH0":      "",
+		"$apr1$12345678$iHG6z5QyvBj5X7XsjhPcb/": "a much longer password than sixteen",
+	}
+
+	for hashed, password := range vectors {
+		if GetAlgorithm(hashed) != ALGORITHM_APR1 || Validate(hashed) != nil {
+			t.Errorf("expected %s to be a valid apr1 hash", hashed)
+		}
+
+		if !Compare(hashed, password) {
+			t.Errorf("expected password to match %s", hashed)
+		}
+
+		if Compare(hashed, password+"x") {
+			t.Errorf("expected wrong password not to match %s", hashed)
+		}
+	}
+}
+
+func TestHashAndCompare(t *testing.T) {
+	// Lowest costs to keep the test fast, restored for other tests
+	oldBcryptCost, oldArgon2Memory, oldArgon2Iterations, oldShaCryptRounds := bcryptCost, argon2Memory, argon2Iterations, shaCryptRounds
+	t.Cleanup(func() {
+		bcryptCost, argon2Memory, argon2Iterations, shaCryptRounds = oldBcryptCost, oldArgon2Memory, oldArgon2Iterations, oldShaCryptRounds
+	})
+
+	bcryptCost, argon2Memory, argon2Iterations, shaCryptRounds = bcrypt.MinCost, 64, 1, shaCryptMinRounds
+
+	for _, algorithm := range Algorithms {
+		hashed, err := Hash("secret", algorithm)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", algorithm, err)
+		}
+
+		if got := GetAlgorithm(hashed); got != algorithm {
+			t.Errorf("%s: expected algorithm to be detected, got %q", algorithm, got)
+		}
+
+		if err := Validate(hashed); err != nil {
+			t.Errorf("%s: unexpected validation error: %s", algorithm, err)
+		}
+
+		if !Compare(hashed, "secret") {
+			t.Errorf("%s: expected password to match", algorithm)
+		}
+
+		if Compare(hashed, "other") {
+			t.Errorf("%s: expected wrong password not to match", algorithm)
+		}
+	}
+
+	if _, err := Hash("secret", "md5"); err == nil {
+		t.Errorf("expected error for unknown algorithm, got nil")
+	}
+}
+
+func TestSha256Compare(t *testing.T) {
+	// Generated with: printf 'secret' | sha256sum
+	hashed := "sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+
+	if !Compare(hashed, "secret") || Compare(hashed, "other") {
+		t.Errorf("expected sha256 comparison")
+	}
+
+	if !Compare("sha256:"+strings.ToUpper(strings.TrimPrefix(hashed, "sha256:")), "secret") {
+		t.Errorf("expected uppercase hex digest to match")
+	}
+
+	if HashKey("secret") != hashed || Validate(hashed) != nil {
+		t.Errorf("expected HashKey to create a valid sha256 hash")
+	}
+
+	// Fast hashes are only accepted for keys
+	if _, err := Hash("secret", ALGORITHM_SHA256); err == nil {
+		t.Errorf("expected error for sha256 password hash, got nil")
+	}
+
+	if err := ValidatePassword(hashed); err == nil {
+		t.Errorf("expected error for sha256 password hash, got nil")
+	}
+}
+
+func TestPlaintextCompare(t *testing.T) {
+	if GetAlgorithm("secret") != "" || Validate("secret") != nil {
+		t.Errorf("expected plaintext password to be valid and have no algorithm")
+	}
+
+	if !Compare("secret", "secret") || Compare("secret", "other") {
+		t.Errorf("expected plaintext comparison")
+	}
+}
+
+func TestValidateMalformedHashes(t *testing.T) {
+	hashes := []string{
+		"$2y$10$short",
+		"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA",
+		"$argon2id$v=16$m=19456,t=2,p=1$c2FsdA$a2V5",
+		"$5$rounds=abc$salt$hash",
+		"$6$salt",
+		"sha256:abc",
+	}
+
+	for _, hashed := range hashes {
+		if err := Validate(hashed); err == nil {
+			t.Errorf("expected error for %q, got nil", hashed)
+		}
+	}
+}
+
+func TestParseHtpasswd(t *testing.T) {
+	entries, err := ParseHtpasswd(strings.NewReader(`
+# Comment
+admin:$2y$05$B33DQAJhti9Q56OrZggJvuuaPB5wkaby.lRCHjZQIwbdj0.RI7eC6
+user:$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5
+apache:$apr1$saltstri$aGfuB7Lcvs2TUeFTqUVfN0
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(entries) != 3 || entries[0].Username != "admin" || entries[1].Username != "user" || entries[2].Username != "apache" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	if !Compare(entries[0].Hash, "admin-password") || !Compare(entries[1].Hash, "Hello world!") || !Compare(entries[2].Hash, "Hello world!") {
+		t.Errorf("expected passwords to match")
+	}
+
+	// Test unsupported and malformed lines
+	for _, content := range []string{"admin:$1$salt$hash", "admin:$apr1$salt$hash", "admin:sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b", "admin", "admin:"} {
+		if _, err := ParseHtpasswd(strings.NewReader(content)); err == nil {
+			t.Errorf("expected error for %q, got nil", content)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Implementation of SHA-crypt ($5$ and $6$ hashes) as specified in https://www.akkadia.org/drepper/SHA-crypt.txt
+
+const (
+	sha256CryptPrefix = "$5$"
+	sha512CryptPrefix = "$6$"
+
+	shaCryptRoundsPrefix  = "rounds="
+	shaCryptDefaultRounds = 5000
+	shaCryptMinRounds     = 1000
+	shaCryptMaxRounds     = 999_999_999
+	shaCryptMaxSaltLength = 16
+
+	shaCryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// Order in which the digest bytes are encoded, in groups of 3
+var (
+	sha256CryptOrder = []int{
+		0, 10, 20, 21, 1, 11, 12, 22, 2, 3, 13, 23, 24, 4, 14,
+		15, 25, 5, 6, 16, 26, 27, 7, 17, 18, 28, 8, 9, 19, 29,
+	}
+
+	sha512CryptOrder = []int{
+		0, 21, 42, 22, 43, 1, 44, 2, 23, 3, 24, 45, 25, 46, 4,
+		47, 5, 26, 6, 27, 48, 28, 49, 7, 50, 8, 29, 9, 30, 51,
+		31, 52, 10, 53, 11, 32, 12, 33, 54, 34, 55, 13, 56, 14, 35,
+		15, 36, 57, 37, 58, 16, 59, 17, 38, 18, 39, 60, 40, 61, 19,
+		62, 20, 41,
+	}
+)
+
+type shaCryptParams struct {
+	prefix string
+	salt   string
+	rounds int
+
+	// Whether rounds were given explicitly, in which case they are part of the output
+	explicitRounds bool
+}
+
+// Parses a hash in the format $5$[rounds=<rounds>$]<salt>$<hash>, or the same with $6$
+func parseShaCrypt(hashed string) (shaCryptParams, error) {
+	params := shaCryptParams{prefix: hashed[:3], rounds: shaCryptDefaultRounds}
+
+	parts := strings.Split(hashed[3:], "$")
+
+	if strings.HasPrefix(parts[0], shaCryptRoundsPrefix) {
+		rounds, err := strconv.Atoi(strings.TrimPrefix(parts[0], shaCryptRoundsPrefix))
+		if err != nil {
+			return params, fmt.Errorf("invalid SHA-crypt hash rounds %q", parts[0])
+		}
+
+		params.rounds = min(max(rounds, shaCryptMinRounds), shaCryptMaxRounds)
+		params.explicitRounds = true
+		parts = parts[1:]
+	}
+
+	if len(parts) != 2 || len(parts[0]) > shaCryptMaxSaltLength || parts[1] == "" {
+		return params, fmt.Errorf("invalid SHA-crypt hash: expected salt (up to %d characters) and hash", shaCryptMaxSaltLength)
+	}
+
+	params.salt = parts[0]
+
+	return params, nil
+}
+
+func randomShaCryptSalt() (string, error) {
+	bytes := make([]byte, shaCryptMaxSaltLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, len(bytes))
+	for i, b := range bytes {
+		salt[i] = shaCryptAlphabet[int(b)%len(shaCryptAlphabet)]
+	}
+
+	return string(salt), nil
+}
+
+// Returns the full SHA-crypt hash of the password
+func (params shaCryptParams) crypt(password string) string {
+	newHash, order := sha256.New, sha256CryptOrder
+	if params.prefix == sha512CryptPrefix {
+		newHash, order = sha512.New, sha512CryptOrder
+	}
+
+	key, salt := []byte(password), []byte(params.salt)
+
+	// Digest B: key, salt, key
+	digest := newHash()
+	digest.Write(key)
+	digest.Write(salt)
+	digest.Write(key)
+	digestB := digest.Sum(nil)
+
+	// Digest A: key, salt, B repeated to the key length, then B or key for each bit of the key length
+	digest = newHash()
+	digest.Write(key)
+	digest.Write(salt)
+	digest.Write(repeatBytes(digestB, len(key)))
+
+	for length := len(key); length > 0; length >>= 1 {
+		if length&1 != 0 {
+			digest.Write(digestB)
+		} else {
+			digest.Write(key)
+		}
+	}
+
+	digestA := digest.Sum(nil)
+
+	// Sequence P: the key hashed as many times as its length
+	digest = newHash()
+	for range key {
+		digest.Write(key)
+	}
+	sequenceP := repeatBytes(digest.Sum(nil), len(key))
+
+	// Sequence S: the salt hashed 16 + A[0] times
+	digest = newHash()
+	for i := 0; i < 16+int(digestA[0]); i++ {
+		digest.Write(salt)
+	}
+	sequenceS := repeatBytes(digest.Sum(nil), len(salt))
+
+	result := digestA
+	for i := 0; i < params.rounds; i++ {
+		digest = newHash()
+
+		if i%2 != 0 {
+			digest.Write(sequenceP)
+		} else {
+			digest.Write(result)
+		}
+
+		if i%3 != 0 {
+			digest.Write(sequenceS)
+		}
+
+		if i%7 != 0 {
+			digest.Write(sequenceP)
+		}
+
+		if i%2 != 0 {
+			digest.Write(result)
+		} else {
+			digest.Write(sequenceP)
+		}
+
+		result = digest.Sum(nil)
+	}
+
+	var out strings.Builder
+	out.WriteString(params.prefix)
+
+	if params.explicitRounds {
+		out.WriteString(fmt.Sprintf("%s%d$", shaCryptRoundsPrefix, params.rounds))
+	}
+
+	out.WriteString(params.salt)
+	out.WriteString("$")
+	out.WriteString(encodeShaCrypt(result, order))
+
+	return out.String()
+}
+
+// Encodes the digest with the crypt alphabet, 3 bytes at a time in the given order, then the remaining bytes
+func encodeShaCrypt(digest []byte, order []int) string {
+	var out strings.Builder
+
+	write := func(value uint, count int) {
+		for i := 0; i < count; i++ {
+			out.WriteByte(shaCryptAlphabet[value&0x3f])
+			value >>= 6
+		}
+	}
+
+	for i := 0; i < len(order); i += 3 {
+		write(uint(digest[order[i]])<<16|uint(digest[order[i+1]])<<8|uint(digest[order[i+2]]), 4)
+	}
+
+	if len(digest) == sha512.Size {
+		write(uint(digest[63]), 2)
+	} else {
+		write(uint(digest[31])<<8|uint(digest[30]), 3)
+	}
+
+	return out.String()
+}
+
+// Repeats the bytes until the given length is reached
+func repeatBytes(bytes []byte, length int) []byte {
+	result := make([]byte, 0, length)
+	for len(result) < length {
+		result = append(result, bytes[:min(len(bytes), length-len(result))]...)
+	}
+
+	return result
+}
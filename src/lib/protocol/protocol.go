@@ -0,0 +1,168 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Version of the control socket protocol, requests with a different version are rejected
+const VERSION = 1
+
+const (
+	COMMAND_PING   = "ping"
+	COMMAND_STOP   = "stop"
+	COMMAND_RELOAD = "reload"
+	COMMAND_STATUS = "status"
+	COMMAND_RULES  = "rules"
+
+	// Takes LogLevelArgs, returns the current level after applying them
+	COMMAND_LOG_LEVEL = "log-level"
+
+	// Takes ResolveArgs, returns the resolution of the URL against the active rules
+	COMMAND_RESOLVE = "resolve"
+)
+
+type LogLevelArgs struct {
+	// Level to set, the level is only returned if empty
+	Level string `json:"level,omitempty"`
+}
+
+type ResolveArgs struct {
+	URL string `json:"url"`
+
+	// Headers sent with the request, such as credentials
+	Headers map[string][]string `json:"headers,omitempty"`
+}
+
+const (
+	ERROR_INVALID_REQUEST     = "invalid-request"
+	ERROR_UNSUPPORTED_VERSION = "unsupported-version"
+	ERROR_UNKNOWN_COMMAND     = "unknown-command"
+	ERROR_INVALID_ARGS        = "invalid-args"
+	ERROR_COMMAND_FAILED      = "command-failed"
+)
+
+// A request sent to the control socket, each request is a single line of JSON
+type Request struct {
+	Version int             `json:"version"`
+	ID      string          `json:"id"`
+	Command string          `json:"command"`
+	Args    json.RawMessage `json:"args,omitempty"`
+}
+
+// A response frame, a request gets one or more frames with its ID, the last of them has Done set
+type Response struct {
+	Version int             `json:"version"`
+	ID      string          `json:"id"`
+	Done    bool            `json:"done"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func NewError(code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Code, err.Message)
+}
+
+// Reads and writes protocol frames over a connection
+type Conn struct {
+	encoder *json.Encoder
+	decoder *json.Decoder
+}
+
+func NewConn(conn io.ReadWriter) *Conn {
+	return &Conn{
+		encoder: json.NewEncoder(conn),
+		decoder: json.NewDecoder(conn),
+	}
+}
+
+func (c *Conn) ReadRequest() (Request, error) {
+	var request Request
+	err := c.decoder.Decode(&request)
+	return request, err
+}
+
+func (c *Conn) WriteResponse(response Response) error {
+	response.Version = VERSION
+	return c.encoder.Encode(response)
+}
+
+// Sends a response frame with the given data marshalled to JSON, nil data is omitted
+func (c *Conn) WriteData(id string, data any, done bool) error {
+	if data == nil {
+		return c.WriteResponse(Response{ID: id, Done: done})
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return c.WriteResponse(Response{ID: id, Done: done, Data: encoded})
+}
+
+func (c *Conn) WriteError(id string, err *Error) error {
+	return c.WriteResponse(Response{ID: id, Done: true, Error: err})
+}
+
+// Sends a request and calls onData with the data of each response frame until the last one
+// Error responses are returned as *Error
+func (c *Conn) Call(command string, args any, onData func(data json.RawMessage) error) error {
+	request := Request{Version: VERSION, ID: newRequestID(), Command: command}
+
+	if args != nil {
+		encodedArgs, err := json.Marshal(args)
+		if err != nil {
+			return fmt.Errorf("could not encode args: %w", err)
+		}
+
+		request.Args = encodedArgs
+	}
+
+	if err := c.encoder.Encode(request); err != nil {
+		return fmt.Errorf("error writing request: %w", err)
+	}
+
+	for {
+		var response Response
+		if err := c.decoder.Decode(&response); err != nil {
+			return fmt.Errorf("error reading response: %w", err)
+		}
+
+		if response.ID != request.ID {
+			return fmt.Errorf("unexpected response id %q, expected %q", response.ID, request.ID)
+		}
+
+		if response.Error != nil {
+			return response.Error
+		}
+
+		if len(response.Data) > 0 {
+			if err := onData(response.Data); err != nil {
+				return err
+			}
+		}
+
+		if response.Done {
+			return nil
+		}
+	}
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -2,20 +2,41 @@ package watchers
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 
 	"github.com/AmrSaber/redirector/src/lib/logger"
 	"github.com/fsnotify/fsnotify"
 )
 
+// Watches the parent directory rather than the file itself, so editors that save by replacing the file are still picked up
 func WatchConfigFile(ctx context.Context, filePath string) (<-chan any, error) {
+	filePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, err
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
 	}
 
+	if err := watcher.Add(filepath.Dir(filePath)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
 	updateChan := make(chan any)
 
+	// The goroutine owns the channel, so it's closed in one place whatever way it stops
 	go func() {
+		defer close(updateChan)
+		defer watcher.Close()
+
 		for {
 			select {
 			case event, ok := <-watcher.Events:
@@ -23,8 +44,12 @@ func WatchConfigFile(ctx context.Context, filePath string) (<-chan any, error) {
 					return
 				}
 
-				if event.Op == fsnotify.Write {
-					updateChan <- nil
+				if event.Name == filePath && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					select {
+					case updateChan <- nil:
+					case <-ctx.Done():
+						return
+					}
 				}
 
 			case err, ok := <-watcher.Errors:
@@ -32,23 +57,14 @@ func WatchConfigFile(ctx context.Context, filePath string) (<-chan any, error) {
 					return
 				}
 
-				logger.Err.Println("file watcher error:", err)
+				logger.Errorf("file watcher error: %s", err)
 
 			case <-ctx.Done():
-				logger.Std.Println("Stopping file watcher...")
-				close(updateChan)
-				watcher.Close()
+				logger.Debugf("Stopping file watcher...")
 				return
 			}
 		}
 	}()
 
-	err = watcher.Add(filePath)
-	if err != nil {
-		close(updateChan)
-		watcher.Close()
-		return nil, err
-	}
-
 	return updateChan, nil
 }
@@ -23,8 +23,16 @@ func main() {
 
 		Commands: []*cli.Command{
 			commands.StartCommand,
+			commands.ValidateCommand,
+			commands.LintCommand,
 			commands.PingCommand,
 			commands.StopCommand,
+			commands.ReloadCommand,
+			commands.StatusCommand,
+			commands.RulesCommand,
+			commands.ResolveCommand,
+			commands.LogLevelCommand,
+			commands.HashPasswordCommand,
 			commands.VersionCommand,
 		},
 	}
@@ -2,11 +2,16 @@ package models
 
 import (
 	"fmt"
-	"net/url"
+	"net/http"
+	"net/netip"
+	"os"
 	"slices"
 	"strings"
 	"time"
 
+	"github.com/AmrSaber/redirector/src/lib/jwt"
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/passwords"
 	"github.com/AmrSaber/redirector/src/utils"
 	"gopkg.in/yaml.v3"
 )
@@ -16,22 +21,118 @@ type Config struct {
 	ConfigURI string    `yaml:"config-uri"`
 	LoadedAt  time.Time `yaml:"loaded-at"`
 
-	Port         int   `yaml:"port"`
-	TempRedirect *bool `yaml:"temp-redirect"`
+	Port         int    `yaml:"port"`
+	TempRedirect *bool  `yaml:"temp-redirect"`
+	Status       int    `yaml:"status,omitempty"`
+	LogLevel     string `yaml:"log-level,omitempty"`
 
-	Auth             *AuthSchema        `yaml:"auth,omitempty"`
-	UrlConfigRefresh *UrlRefreshOptions `yaml:"url-config-refresh,omitempty"` // TODO make into pointer
+	Auth             *AuthSchema              `yaml:"auth,omitempty"`
+	UrlConfigRefresh *UrlRefreshOptions       `yaml:"url-config-refresh,omitempty"` // TODO make into pointer
+	Tls              *TlsOptions              `yaml:"tls,omitempty"`
+	Metrics          *MetricsOptions          `yaml:"metrics,omitempty"`
+	AccessLog        *logger.AccessLogOptions `yaml:"access-log,omitempty"`
 
 	Redirects []Redirect `yaml:"redirects"`
+
+	// Checked against the redirects on each load, a config with failing tests is not loaded
+	Tests []RedirectTest `yaml:"tests,omitempty"`
 }
 
 type AuthSchema struct {
-	BasicAuth map[string]*BasicAuthSchema `yaml:"basic-auth,omitempty"`
+	BasicAuth   map[string]*BasicAuthSchema   `yaml:"basic-auth,omitempty"`
+	ForwardAuth map[string]*ForwardAuthSchema `yaml:"forward-auth,omitempty"`
+	OIDC        map[string]*OIDCSchema        `yaml:"oidc,omitempty"`
+	Jwt         map[string]*JwtSchema         `yaml:"jwt,omitempty"`
+	ApiKey      map[string]*ApiKeySchema      `yaml:"api-key,omitempty"`
+
+	// Brute-force protection, disabled if not provided
+	Protection *AuthProtectionOptions `yaml:"protection,omitempty"`
+}
+
+type AuthProtectionOptions struct {
+	// Failed attempts after which the client IP or the username is locked out, 0 disables the lockout
+	MaxIPFailures   *int `yaml:"max-ip-failures,omitempty"`
+	MaxUserFailures *int `yaml:"max-user-failures,omitempty"`
+
+	// Failed attempts are forgotten after this duration without new failures
+	Window time.Duration `yaml:"window"`
+
+	// How long a lockout lasts
+	Lockout time.Duration `yaml:"lockout"`
+
+	// Delay of the response to the first failed attempt, doubled on each subsequent failure up to max delay
+	Delay    time.Duration `yaml:"delay"`
+	MaxDelay time.Duration `yaml:"max-delay"`
+
+	// Addresses or CIDR ranges of reverse proxies in front of redirector, whose X-Forwarded-For header is trusted
+	TrustedProxies []string `yaml:"trusted-proxies,omitempty"`
+
+	// Trusted proxies parsed on load
+	trustedProxies []netip.Prefix
+}
+
+// Returns the client IP of the request, taken from X-Forwarded-For if the request comes from a trusted proxy
+// The rightmost address that is not a trusted proxy is used, as the addresses before it can be set by the client
+func (protection AuthProtectionOptions) GetClientIP(req *http.Request) string {
+	clientIP := utils.StripPort(req.RemoteAddr)
+	if !protection.isTrustedProxy(clientIP) {
+		return clientIP
+	}
+
+	forwardedFor := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwardedFor[i])
+		if address == "" {
+			continue
+		}
+
+		if !protection.isTrustedProxy(address) {
+			return address
+		}
+
+		clientIP = address
+	}
+
+	return clientIP
+}
+
+func (protection AuthProtectionOptions) isTrustedProxy(address string) bool {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return false
+	}
+
+	for _, prefix := range protection.trustedProxies {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Parses a trusted proxy, a single address is treated as a range of that address only
+func parseTrustedProxy(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		return prefix.Masked(), err
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
 }
 
 type BasicAuthSchema struct {
-	Realm string          `yaml:"realm"`
-	Users []BasicAuthUser `yaml:"users"`
+	Realm        string          `yaml:"realm"`
+	Users        []BasicAuthUser `yaml:"users"`
+	HtpasswdFile string          `yaml:"htpasswd-file,omitempty"`
+
+	// Users read from the htpasswd file on load
+	htpasswdUsers []BasicAuthUser
 }
 
 func (auth BasicAuthSchema) FindMatchingUser(username string) *BasicAuthUser {
@@ -41,12 +142,284 @@ func (auth BasicAuthSchema) FindMatchingUser(username string) *BasicAuthUser {
 		}
 	}
 
+	for _, userConfig := range auth.htpasswdUsers {
+		if userConfig.Username == username {
+			return &userConfig
+		}
+	}
+
 	return nil
 }
 
+// Returns the password of the first user of the schema, or empty string if it has no users
+// Used as a stand-in for unknown users, so that checking them takes as long as checking known users
+func (auth BasicAuthSchema) getFirstPassword() string {
+	if len(auth.Users) > 0 {
+		return auth.Users[0].GetPassword()
+	}
+
+	if len(auth.htpasswdUsers) > 0 {
+		return auth.htpasswdUsers[0].GetPassword()
+	}
+
+	return ""
+}
+
 type BasicAuthUser struct {
 	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+
+	// Plaintext password or a hash (bcrypt, argon2id or SHA-crypt), detected by its prefix
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"password-file,omitempty"`
+
+	// Password read from the password file on load, kept out of the printed config
+	filePassword string
+}
+
+// Returns the plaintext password or hash to check against
+func (user BasicAuthUser) GetPassword() string {
+	if user.PasswordFile != "" {
+		return user.filePassword
+	}
+
+	return user.Password
+}
+
+// Delegates the auth decision to an external endpoint, which is called with the headers of each request
+// The request is allowed if the endpoint responds with 2xx, otherwise the endpoint response is returned to the client
+type ForwardAuthSchema struct {
+	Address string `yaml:"address"`
+
+	// Headers of the original request sent to the endpoint, all headers are sent if empty
+	RequestHeaders []string `yaml:"request-headers,omitempty"`
+
+	// Headers copied from the endpoint response when the request is allowed
+	ResponseHeaders []string `yaml:"response-headers,omitempty"`
+
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Sends unauthenticated users to log in with an OpenID Connect provider, then keeps them logged in with a signed session cookie
+// Users can be restricted by email domain, groups or an explicit list, any authenticated user is allowed if no restriction is set
+type OIDCSchema struct {
+	Issuer           string   `yaml:"issuer"`
+	ClientID         string   `yaml:"client-id"`
+	ClientSecret     string   `yaml:"client-secret,omitempty"`
+	ClientSecretFile string   `yaml:"client-secret-file,omitempty"`
+	Scopes           []string `yaml:"scopes,omitempty"`
+
+	// Path on the redirect domain that the provider sends users back to, must be registered with the provider
+	CallbackPath string `yaml:"callback-path"`
+
+	// Session cookie name and the secret used to sign it
+	// Sessions do not survive restarts if no secret is provided
+	CookieName       string        `yaml:"cookie-name"`
+	CookieSecret     string        `yaml:"cookie-secret,omitempty"`
+	CookieSecretFile string        `yaml:"cookie-secret-file,omitempty"`
+	SessionDuration  time.Duration `yaml:"session-duration"`
+
+	AllowedEmailDomains []string `yaml:"allowed-email-domains,omitempty"`
+	AllowedGroups       []string `yaml:"allowed-groups,omitempty"`
+	AllowedUsers        []string `yaml:"allowed-users,omitempty"`
+
+	// Claim of the ID token that lists the user groups
+	GroupsClaim string `yaml:"groups-claim"`
+
+	// Name of the schema and secrets read from the secret files on load
+	name             string
+	fileClientSecret string
+	fileCookieSecret string
+}
+
+// Returns the name of the schema in the config
+func (auth OIDCSchema) GetName() string {
+	return auth.name
+}
+
+// Returns the effective client secret
+func (auth OIDCSchema) GetClientSecret() string {
+	if auth.ClientSecretFile != "" {
+		return auth.fileClientSecret
+	}
+
+	return auth.ClientSecret
+}
+
+// Returns the effective cookie secret, or empty string if none is configured
+func (auth OIDCSchema) GetCookieSecret() string {
+	if auth.CookieSecretFile != "" {
+		return auth.fileCookieSecret
+	}
+
+	return auth.CookieSecret
+}
+
+// Checks whether the user is allowed by the configured restrictions, any matching restriction allows the user
+// Users are matched by subject or verified email, as unverified emails can be set to anything by the user on some providers
+func (auth OIDCSchema) IsUserAllowed(subject, email string, emailVerified bool, groups []string) bool {
+	if len(auth.AllowedEmailDomains) == 0 && len(auth.AllowedGroups) == 0 && len(auth.AllowedUsers) == 0 {
+		return true
+	}
+
+	for _, user := range auth.AllowedUsers {
+		if user == subject || (emailVerified && email != "" && strings.EqualFold(user, email)) {
+			return true
+		}
+	}
+
+	if emailVerified {
+		if _, domain, found := strings.Cut(email, "@"); found {
+			for _, allowedDomain := range auth.AllowedEmailDomains {
+				if strings.EqualFold(strings.TrimPrefix(allowedDomain, "@"), domain) {
+					return true
+				}
+			}
+		}
+	}
+
+	for _, group := range groups {
+		if slices.Contains(auth.AllowedGroups, group) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Accepts requests with a valid signed JWT, sent as a bearer token or in a query parameter
+type JwtSchema struct {
+	// Key to verify tokens with, exactly one of: HMAC secret, HMAC secret file, PEM public key file or JWKS file
+	Secret        string `yaml:"secret,omitempty"`
+	SecretFile    string `yaml:"secret-file,omitempty"`
+	PublicKeyFile string `yaml:"public-key-file,omitempty"`
+	JwksFile      string `yaml:"jwks-file,omitempty"`
+
+	// Accepted signing algorithms, any algorithm matching the key type is accepted if empty
+	Algorithms []string `yaml:"algorithms,omitempty"`
+
+	// Expected "iss" and "aud" claims, not checked if empty
+	Issuer   string `yaml:"issuer,omitempty"`
+	Audience string `yaml:"audience,omitempty"`
+
+	// Claims that must have the given values, a list claim must contain the value
+	Claims map[string]string `yaml:"claims,omitempty"`
+
+	// Query parameter to read the token from if the request has no bearer token
+	QueryParam string `yaml:"query-param,omitempty"`
+
+	// Accept tokens without an "exp" claim, which are otherwise rejected as they would be valid forever
+	AllowNoExpiry bool `yaml:"allow-no-expiry,omitempty"`
+
+	// Keys read on load
+	keys jwt.KeySet
+}
+
+// Reads the token of the request, or returns empty string if there is none
+func (auth JwtSchema) GetToken(req *http.Request) string {
+	if scheme, token, found := strings.Cut(req.Header.Get("Authorization"), " "); found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	if auth.QueryParam != "" {
+		return req.URL.Query().Get(auth.QueryParam)
+	}
+
+	return ""
+}
+
+// Checks whether the request has a valid token
+func (auth JwtSchema) IsAuthorized(req *http.Request) bool {
+	rawToken := auth.GetToken(req)
+	if rawToken == "" {
+		return false
+	}
+
+	token, err := jwt.Parse(rawToken)
+	if err != nil {
+		return false
+	}
+
+	if len(auth.Algorithms) > 0 && !slices.Contains(auth.Algorithms, token.Header.Algorithm) {
+		return false
+	}
+
+	if err := token.Verify(auth.keys); err != nil {
+		return false
+	}
+
+	options := jwt.ValidationOptions{Issuer: auth.Issuer, Audience: auth.Audience, RequireExpiry: !auth.AllowNoExpiry, Leeway: time.Minute}
+	if err := token.Claims.Validate(options); err != nil {
+		return false
+	}
+
+	for name, value := range auth.Claims {
+		if !token.Claims.HasValue(name, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Accepts requests with one of the configured keys, sent in a header or in a query parameter
+type ApiKeySchema struct {
+	// Header to read the key from
+	Header string `yaml:"header"`
+
+	// Query parameter to read the key from if the header is not set, keys are only read from the header if empty
+	QueryParam string `yaml:"query-param,omitempty"`
+
+	Keys []ApiKey `yaml:"keys"`
+}
+
+type ApiKey struct {
+	// Name of the key, to tell keys apart in logs
+	Name string `yaml:"name"`
+
+	// Plaintext key or a sha256:<hex> hash, slow hashes are not allowed as each request is checked against every key
+	Key     string `yaml:"key,omitempty"`
+	KeyFile string `yaml:"key-file,omitempty"`
+
+	// Key read from the key file on load
+	fileKey string
+}
+
+// Returns the plaintext key or hash to check against
+func (key ApiKey) GetKey() string {
+	if key.KeyFile != "" {
+		return key.fileKey
+	}
+
+	return key.Key
+}
+
+// Reads the key of the request, or returns empty string if there is none
+func (auth ApiKeySchema) GetRequestKey(req *http.Request) string {
+	if key := req.Header.Get(auth.Header); key != "" {
+		return key
+	}
+
+	if auth.QueryParam != "" {
+		return req.URL.Query().Get(auth.QueryParam)
+	}
+
+	return ""
+}
+
+// Returns the configured key that matches the key of the request, or nil if none matches
+func (auth ApiKeySchema) FindMatchingKey(req *http.Request) *ApiKey {
+	requestKey := auth.GetRequestKey(req)
+	if requestKey == "" {
+		return nil
+	}
+
+	for _, key := range auth.Keys {
+		if passwords.Compare(key.GetKey(), requestKey) {
+			return &key
+		}
+	}
+
+	return nil
 }
 
 type UrlRefreshOptions struct {
@@ -73,6 +446,50 @@ type RefreshDomain struct {
 	RefreshOn string `yaml:"refresh-on"`
 }
 
+type TlsOptions struct {
+	// The port for the HTTPS listener
+	Port int `yaml:"port"`
+
+	// Certificate and key pairs
+	Certificates []TlsCertificate `yaml:"certificates,omitempty"`
+
+	// Directory containing certificate and key pairs named <name>.crt and <name>.key
+	CertificatesDir string `yaml:"certificates-dir,omitempty"`
+
+	// Automatic certificates issuance
+	Acme *AcmeOptions `yaml:"acme,omitempty"`
+}
+
+type AcmeOptions struct {
+	// Contact email for the ACME account
+	Email string `yaml:"email,omitempty"`
+
+	// ACME directory to issue certificates from
+	DirectoryURL string `yaml:"directory-url"`
+
+	// CA certificate to trust when connecting to the ACME directory, useful for test servers
+	DirectoryCA string `yaml:"directory-ca,omitempty"`
+
+	// Directory to store issued certificates and account keys in
+	CacheDir string `yaml:"cache-dir"`
+}
+
+type TlsCertificate struct {
+	// Domains to serve this certificate for, defaults to the domains in the certificate itself
+	Domains []string `yaml:"domains,omitempty"`
+
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+type MetricsOptions struct {
+	// The port for the metrics listener
+	Port int `yaml:"port"`
+
+	// The path to expose metrics on
+	Path string `yaml:"path"`
+}
+
 var _DEFAULT_TEMP_REDIRECT = true
 
 func NewConfig(source, uri string) *Config {
@@ -88,8 +505,12 @@ func (c *Config) Load(yamlBody []byte) error {
 		return fmt.Errorf("could not parse configs from yaml: %s", err)
 	}
 
-	if err := parsedConfig.validate(); err != nil {
-		return fmt.Errorf("invalid configurations:\n%s", err)
+	if diagnostics := parsedConfig.validate(); diagnostics.HasErrors() {
+		return fmt.Errorf("invalid configurations:\n%s", diagnostics.ErrorMessages())
+	}
+
+	if diagnostics := parsedConfig.loadAuthFiles(); diagnostics.HasErrors() {
+		return fmt.Errorf("could not load auth files:\n%s", diagnostics.ErrorMessages())
 	}
 
 	c.copyFrom(&parsedConfig)
@@ -101,6 +522,75 @@ func (c *Config) Load(yamlBody []byte) error {
 				auth.Realm = utils.DEFAULT_REALM
 			}
 		}
+
+		for _, auth := range c.Auth.ForwardAuth {
+			if auth.Timeout == 0 {
+				auth.Timeout = 10 * time.Second
+			}
+		}
+
+		for _, auth := range c.Auth.ApiKey {
+			if auth.Header == "" {
+				auth.Header = "X-API-Key"
+			}
+		}
+
+		for key, auth := range c.Auth.OIDC {
+			auth.name = key
+
+			if len(auth.Scopes) == 0 {
+				auth.Scopes = []string{"openid", "email", "profile"}
+			}
+
+			if auth.CallbackPath == "" {
+				auth.CallbackPath = utils.DEFAULT_OIDC_CALLBACK_PATH
+			}
+
+			if auth.CookieName == "" {
+				auth.CookieName = "redirector_session"
+			}
+
+			if auth.SessionDuration == 0 {
+				auth.SessionDuration = 12 * time.Hour
+			}
+
+			if auth.GroupsClaim == "" {
+				auth.GroupsClaim = "groups"
+			}
+		}
+
+		if protection := c.Auth.Protection; protection != nil {
+			if protection.MaxIPFailures == nil {
+				protection.MaxIPFailures = utils.ToPointer(20)
+			}
+
+			if protection.MaxUserFailures == nil {
+				protection.MaxUserFailures = utils.ToPointer(5)
+			}
+
+			if protection.Window == 0 {
+				protection.Window = 15 * time.Minute
+			}
+
+			if protection.Lockout == 0 {
+				protection.Lockout = 15 * time.Minute
+			}
+
+			if protection.Delay == 0 {
+				protection.Delay = 500 * time.Millisecond
+			}
+
+			if protection.MaxDelay == 0 {
+				protection.MaxDelay = 10 * time.Second
+			}
+
+			// Already validated
+			protection.trustedProxies = make([]netip.Prefix, 0, len(protection.TrustedProxies))
+			for _, value := range protection.TrustedProxies {
+				prefix, _ := parseTrustedProxy(value)
+				protection.trustedProxies = append(protection.trustedProxies, prefix)
+			}
+		}
 	}
 
 	if c.Source == SOURCE_URL {
@@ -117,161 +607,153 @@ func (c *Config) Load(yamlBody []byte) error {
 		c.Port = 80
 	}
 
+	if c.Tls != nil && c.Tls.Port == 0 {
+		c.Tls.Port = 443
+	}
+
+	if c.AccessLog != nil {
+		if c.AccessLog.Format == "" {
+			c.AccessLog.Format = logger.ACCESS_LOG_FORMAT_JSON
+		}
+
+		if c.AccessLog.Output == "" {
+			c.AccessLog.Output = logger.ACCESS_LOG_OUTPUT_STDOUT
+		}
+	}
+
+	if c.Metrics != nil && c.Metrics.Path == "" {
+		c.Metrics.Path = "/metrics"
+	}
+
+	if c.Tls != nil && c.Tls.Acme != nil && c.Tls.Acme.DirectoryURL == "" {
+		c.Tls.Acme.DirectoryURL = utils.DEFAULT_ACME_DIRECTORY
+	}
+
+	// "temp-redirect" is a shorthand for the status, both are filled so that the printed config shows the effective values
+	if c.Status != 0 {
+		tempRedirect := isTemporaryStatus(c.Status)
+		c.TempRedirect = &tempRedirect
+	}
+
 	if c.TempRedirect == nil {
 		c.TempRedirect = &_DEFAULT_TEMP_REDIRECT
 	}
 
+	if c.Status == 0 {
+		c.Status = getTempRedirectStatus(*c.TempRedirect)
+	}
+
 	for i, r := range c.Redirects {
-		if r.TempRedirect == nil {
+		// The status of the redirect takes precedence, then its temp-redirect, then the global status
+		if r.Mode != MODE_PROXY {
+			if r.Status == 0 && r.TempRedirect != nil {
+				r.Status = getTempRedirectStatus(*r.TempRedirect)
+			}
+
+			if r.Status == 0 {
+				r.Status = c.Status
+			}
+
+			tempRedirect := isTemporaryStatus(r.Status)
+			r.TempRedirect = &tempRedirect
+		} else if r.TempRedirect == nil {
 			r.TempRedirect = c.TempRedirect
 		}
 
-		// Add actual auth objects to redirect for simpler authentication
-		if len(r.AuthNames) > 0 {
-			r.ActualAuths.BasicAuth = make(map[string]*BasicAuthSchema)
+		r.index = i
 
-			for _, authName := range r.AuthNames {
-				r.ActualAuths.BasicAuth[authName] = c.Auth.BasicAuth[authName]
-			}
+		if r.Mode == "" {
+			r.Mode = MODE_REDIRECT
 		}
 
-		c.Redirects[i] = r
-	}
+		if r.Path != "" && r.PathMatch == "" {
+			r.PathMatch = PATH_MATCH_PREFIX
+		}
 
-	return nil
-}
+		// Compile regex once, it's already validated
+		if r.FromRegex != "" {
+			r.fromRegex, _ = compileFromRegex(r.FromRegex)
+		}
 
-// Validates the config
-func (c Config) validate() error {
-	errors := []string{}
+		// Add actual auth objects to redirect for simpler authentication
+		if len(r.AuthNames) > 0 {
+			r.ActualAuths.BasicAuth = make(map[string]*BasicAuthSchema)
+			r.ActualAuths.ForwardAuth = make(map[string]*ForwardAuthSchema)
+			r.ActualAuths.OIDC = make(map[string]*OIDCSchema)
+			r.ActualAuths.Jwt = make(map[string]*JwtSchema)
+			r.ActualAuths.ApiKey = make(map[string]*ApiKeySchema)
 
-	// Validate auth
-	if c.Auth != nil {
-		for key, auth := range c.Auth.BasicAuth {
-			// Validate that each object contains a username and a password
-			for i, userConfig := range auth.Users {
-				if userConfig.Username == "" {
-					errors = append(errors, fmt.Sprintf(`Auth "username" must be provided [@basic-auth %q #%d]`, key, i))
+			for _, authName := range r.AuthNames {
+				if auth, ok := c.Auth.BasicAuth[authName]; ok {
+					r.ActualAuths.BasicAuth[authName] = auth
 				}
 
-				if userConfig.Password == "" {
-					errors = append(errors, fmt.Sprintf(`Auth "password" must be provided [@basic-auth %q #%d]`, key, i))
+				if auth, ok := c.Auth.ForwardAuth[authName]; ok {
+					r.ActualAuths.ForwardAuth[authName] = auth
 				}
-			}
-		}
 
-		// Validate that there are no duplicate usernames within same schema
-		{
-			for authName, auth := range c.Auth.BasicAuth {
-				authUsers := make(map[string][]int, len(auth.Users))
+				if auth, ok := c.Auth.OIDC[authName]; ok {
+					r.ActualAuths.OIDC[authName] = auth
+				}
 
-				for i, userConfig := range auth.Users {
-					username := userConfig.Username
-					authUsers[username] = append(authUsers[username], i)
+				if auth, ok := c.Auth.Jwt[authName]; ok {
+					r.ActualAuths.Jwt[authName] = auth
 				}
 
-				for username, occurrences := range authUsers {
-					if len(occurrences) <= 1 {
-						continue
-					}
-
-					errors = append(
-						errors,
-						fmt.Sprintf(
-							"Found duplicate username %q in basic-auth %q at: %s",
-							username,
-							authName,
-							strings.Join(utils.ToStringSlice(occurrences), ", "),
-						),
-					)
+				if auth, ok := c.Auth.ApiKey[authName]; ok {
+					r.ActualAuths.ApiKey[authName] = auth
 				}
 			}
 		}
+
+		c.Redirects[i] = r
 	}
 
-	for i, r := range c.Redirects {
-		// Trim trailing slash from each domain
-		r.From = strings.TrimSuffix(r.From, "/")
-		r.To = strings.TrimSuffix(r.To, "/")
+	return nil
+}
 
-		// Validate that each "from" is a valid domain name and each "to" is a valid URL
-		if !utils.DomainRegex.MatchString(r.From) {
-			errors = append(errors, fmt.Sprintf(`Invalid "from" domain [#%d]: %s`, i, r.From))
-		}
+// Reads the verification keys of the schema from its configured source
+func (auth JwtSchema) loadKeys() (jwt.KeySet, error) {
+	switch {
+	case auth.Secret != "":
+		return jwt.KeySet{{Key: []byte(auth.Secret)}}, nil
 
-		if !utils.UrlRegex.MatchString(r.To) {
-			errors = append(errors, fmt.Sprintf(`Invalid "to" URL [#%d]: %s`, i, r.To))
+	case auth.SecretFile != "":
+		content, err := os.ReadFile(auth.SecretFile)
+		if err != nil {
+			return nil, err
 		}
 
-		if r.PreservePath && utils.HasPathRegex.MatchString(r.To) {
-			errors = append(errors, fmt.Sprintf(`"To" URL cannot contain path and set preserve path [#%d]: %s`, i, r.To))
+		secret := strings.TrimRight(string(content), "\r\n")
+		if secret == "" {
+			return nil, fmt.Errorf("empty secret file: %s", auth.SecretFile)
 		}
 
-		if toWildcardsCount := strings.Count(r.To, "*"); toWildcardsCount > 0 {
-			toUrl, _ := url.Parse(r.To)
+		return jwt.KeySet{{Key: []byte(secret)}}, nil
 
-			toSectionsCount := len(strings.Split(toUrl.Host, "."))
-			fromSectionsCount := len(strings.Split(r.From, "."))
-
-			if toSectionsCount != fromSectionsCount {
-				errors = append(
-					errors,
-					fmt.Sprintf(
-						`"to" has wildcard(s) but "To" sections (found %d) and "From" sections (found %d) don't match `,
-						toSectionsCount,
-						fromSectionsCount,
-					),
-				)
-			}
+	case auth.PublicKeyFile != "":
+		content, err := os.ReadFile(auth.PublicKeyFile)
+		if err != nil {
+			return nil, err
 		}
 
-		if len(r.AuthNames) > 0 {
-			realms := make(map[string]any)
-			availableAuths := c.GetAvailableAuthNames()
-			for _, authName := range r.AuthNames {
-				if slices.Contains(availableAuths, authName) {
-					realm := c.Auth.BasicAuth[authName].Realm
-					if realm == "" {
-						realm = utils.DEFAULT_REALM
-					}
-
-					realms[realm] = struct{}{}
-				} else {
-					errors = append(errors, fmt.Sprintf("Auth %q not found [@redirect#%d]", authName, i))
-				}
-			}
-
-			// Validate that there are no mixed realms
-			if len(realms) > 1 {
-				foundRealms := utils.MapSlice(utils.GetMapKeys(realms), func(str string) string { return fmt.Sprintf("%q", str) })
-				errors = append(
-					errors,
-					fmt.Sprintf(
-						"Found mixed realms (%s) at redirect #%d. All linked auths must have the same realm",
-						strings.Join(foundRealms, ", "), i,
-					),
-				)
-			}
+		key, err := jwt.ParsePublicKeyPEM(content)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	if c.UrlConfigRefresh != nil {
-		for i, d := range c.UrlConfigRefresh.RefreshDomains {
-			if !utils.DomainRegex.MatchString(d.Domain) {
-				errors = append(errors, fmt.Sprintf(`Invalid "domain" for refresh domains [#%d]: %s`, i, d.Domain))
-			}
+		return jwt.KeySet{{Key: key}}, nil
 
-			if d.RefreshOn != REFRESH_ON_HIT && d.RefreshOn != REFRESH_ON_MISS {
-				errors = append(errors, fmt.Sprintf(`Invalid "refresh-on" for refresh domains [#%d]: %s`, i, d.RefreshOn))
-			}
+	case auth.JwksFile != "":
+		content, err := os.ReadFile(auth.JwksFile)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	if len(errors) != 0 {
-		return fmt.Errorf(strings.Join(errors, "\n"))
-	} else {
-		return nil
+		return jwt.ParseJWKS(content)
 	}
+
+	return nil, nil
 }
 
 func (c Config) GetAvailableAuthNames() []string {
@@ -280,6 +762,22 @@ func (c Config) GetAvailableAuthNames() []string {
 		for key := range c.Auth.BasicAuth {
 			auths = append(auths, key)
 		}
+
+		for key := range c.Auth.ForwardAuth {
+			auths = append(auths, key)
+		}
+
+		for key := range c.Auth.OIDC {
+			auths = append(auths, key)
+		}
+
+		for key := range c.Auth.Jwt {
+			auths = append(auths, key)
+		}
+
+		for key := range c.Auth.ApiKey {
+			auths = append(auths, key)
+		}
 	}
 	return auths
 }
@@ -292,10 +790,16 @@ func (c Config) IsStale() bool {
 func (c *Config) copyFrom(other *Config) {
 	c.Port = other.Port
 	c.TempRedirect = other.TempRedirect
+	c.Status = other.Status
+	c.LogLevel = other.LogLevel
 
 	c.Auth = other.Auth
 	c.UrlConfigRefresh = other.UrlConfigRefresh
+	c.Tls = other.Tls
+	c.Metrics = other.Metrics
+	c.AccessLog = other.AccessLog
 	c.Redirects = other.Redirects
+	c.Tests = other.Tests
 }
 
 // Prints the config as yaml
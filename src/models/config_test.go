@@ -1,7 +1,20 @@
 package models
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/lib/jwt"
+	"github.com/AmrSaber/redirector/src/lib/jwt/jwttest"
 )
 
 func TestConfigValidation(t *testing.T) {
@@ -12,9 +25,9 @@ func TestConfigValidation(t *testing.T) {
 		},
 	}
 
-	err := configs.validate()
-	if err != nil {
-		t.Errorf("unexpected error: %s", err)
+	diagnostics := configs.validate()
+	if diagnostics.HasErrors() {
+		t.Errorf("unexpected error: %s", diagnostics.ErrorMessages())
 	}
 
 	// Test invalid "from"
@@ -24,8 +37,8 @@ func TestConfigValidation(t *testing.T) {
 		},
 	}
 
-	err = configs.validate()
-	if err == nil {
+	diagnostics = configs.validate()
+	if !diagnostics.HasErrors() {
 		t.Errorf("expected error on 'from', got nil")
 	}
 
@@ -36,8 +49,8 @@ func TestConfigValidation(t *testing.T) {
 		},
 	}
 
-	err = configs.validate()
-	if err == nil {
+	diagnostics = configs.validate()
+	if !diagnostics.HasErrors() {
 		t.Errorf("expected error on 'to', got nil")
 	}
 
@@ -48,8 +61,8 @@ func TestConfigValidation(t *testing.T) {
 		},
 	}
 
-	err = configs.validate()
-	if err == nil {
+	diagnostics = configs.validate()
+	if !diagnostics.HasErrors() {
 		t.Errorf("expected error on 'preserve-path', got nil")
 	}
 
@@ -61,9 +74,9 @@ func TestConfigValidation(t *testing.T) {
 		},
 	}
 
-	err = configs.validate()
-	if err != nil {
-		t.Errorf(`unexpected error on "to" wildcard path: %q`, err)
+	diagnostics = configs.validate()
+	if diagnostics.HasErrors() {
+		t.Errorf(`unexpected error on "to" wildcard path: %q`, diagnostics.ErrorMessages())
 	}
 
 	// Different count
@@ -73,8 +86,752 @@ func TestConfigValidation(t *testing.T) {
 		},
 	}
 
-	err = configs.validate()
-	if err == nil {
+	diagnostics = configs.validate()
+	if !diagnostics.HasErrors() {
 		t.Errorf(`expected error on "to" wildcard path, got nil`)
 	}
 }
+
+func TestConfigPathValidation(t *testing.T) {
+	// Prefix path can have a path in "to" with preserve-path
+	configs := Config{
+		Redirects: []Redirect{
+			{From: "example.com", Path: "/docs", To: "https://target.com/v2", PreservePath: true},
+		},
+	}
+
+	if diagnostics := configs.validate(); diagnostics.HasErrors() {
+		t.Errorf("unexpected error: %s", diagnostics.ErrorMessages())
+	}
+
+	// Exact path cannot
+	configs = Config{
+		Redirects: []Redirect{
+			{From: "example.com", Path: "/docs", PathMatch: PATH_MATCH_EXACT, To: "https://target.com/v2", PreservePath: true},
+		},
+	}
+
+	if diagnostics := configs.validate(); !diagnostics.HasErrors() {
+		t.Errorf("expected error on 'preserve-path', got nil")
+	}
+
+	// Path must be absolute
+	configs = Config{
+		Redirects: []Redirect{
+			{From: "example.com", Path: "docs", To: "https://target.com"},
+		},
+	}
+
+	if diagnostics := configs.validate(); !diagnostics.HasErrors() {
+		t.Errorf("expected error on 'path', got nil")
+	}
+
+	// Invalid path-match
+	configs = Config{
+		Redirects: []Redirect{
+			{From: "example.com", Path: "/docs", PathMatch: "regex", To: "https://target.com"},
+		},
+	}
+
+	if diagnostics := configs.validate(); !diagnostics.HasErrors() {
+		t.Errorf("expected error on 'path-match', got nil")
+	}
+
+	// Invalid glob
+	configs = Config{
+		Redirects: []Redirect{
+			{From: "example.com", Path: "/docs/[a", PathMatch: PATH_MATCH_GLOB, To: "https://target.com"},
+		},
+	}
+
+	if diagnostics := configs.validate(); !diagnostics.HasErrors() {
+		t.Errorf("expected error on glob 'path', got nil")
+	}
+}
+
+func TestConfigRegexValidation(t *testing.T) {
+	// Test happy scenario
+	configs := Config{
+		Redirects: []Redirect{
+			{FromRegex: `(\w+)\.example\.com/items/(?P<id>\d+)`, To: "https://$1.target.com/products/${id}"},
+		},
+	}
+
+	if diagnostics := configs.validate(); diagnostics.HasErrors() {
+		t.Errorf("unexpected error: %s", diagnostics.ErrorMessages())
+	}
+
+	// Test invalid regex
+	configs = Config{
+		Redirects: []Redirect{
+			{FromRegex: `example\.com/(\d+`, To: "https://target.com"},
+		},
+	}
+
+	if diagnostics := configs.validate(); !diagnostics.HasErrors() {
+		t.Errorf("expected error on 'from-regex', got nil")
+	}
+
+	// Test undefined references
+	for _, to := range []string{"https://target.com/$2", "https://target.com/${slug}"} {
+		configs = Config{
+			Redirects: []Redirect{
+				{FromRegex: `example\.com/(\d+)`, To: to},
+			},
+		}
+
+		if diagnostics := configs.validate(); !diagnostics.HasErrors() {
+			t.Errorf("expected error on 'to' reference %q, got nil", to)
+		}
+	}
+
+	// Test combining with "from"
+	configs = Config{
+		Redirects: []Redirect{
+			{From: "example.com", FromRegex: `example\.com/.*`, To: "https://target.com"},
+		},
+	}
+
+	if diagnostics := configs.validate(); !diagnostics.HasErrors() {
+		t.Errorf("expected error on 'from' with 'from-regex', got nil")
+	}
+}
+
+func TestConfigMultiLabelWildcardValidation(t *testing.T) {
+	// Test happy scenario
+	configs := Config{
+		Redirects: []Redirect{
+			{From: "**.example.com", To: "https://**.target.com"},
+			{From: "**.example.com", To: "https://target.com"},
+		},
+	}
+
+	if diagnostics := configs.validate(); diagnostics.HasErrors() {
+		t.Errorf("unexpected error: %s", diagnostics.ErrorMessages())
+	}
+
+	// Test "**" at different positions
+	configs = Config{
+		Redirects: []Redirect{
+			{From: "a.**.com", To: "https://**.target.com"},
+		},
+	}
+
+	if diagnostics := configs.validate(); !diagnostics.HasErrors() {
+		t.Errorf(`expected error on "**" position, got nil`)
+	}
+
+	// Test "*" in "to" with "**" in "from"
+	configs = Config{
+		Redirects: []Redirect{
+			{From: "**.example.com", To: "https://*.target.com"},
+		},
+	}
+
+	if diagnostics := configs.validate(); !diagnostics.HasErrors() {
+		t.Errorf(`expected error on "**" position, got nil`)
+	}
+
+	// Test several "**"
+	configs = Config{
+		Redirects: []Redirect{
+			{From: "**.example.**", To: "https://target.com"},
+		},
+	}
+
+	if diagnostics := configs.validate(); !diagnostics.HasErrors() {
+		t.Errorf(`expected error on several "**", got nil`)
+	}
+}
+
+func TestBasicAuthFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	// Hashes with the lowest costs to keep the test fast
+	hashed := "$5$rounds=1000$uV4LCNYWbK38IP.Q$wOmkJoBCBvhsTMiWDiTNBhxm7CwA2.h5MRsk9wwDJa3"
+	htpasswdHash := "$2a$04$SUECnRSxbEUzVlZqup6LLeJ0ndFMWIP4S/8Y9paBJuDGVLECGD7e6"
+	inlineHash := "$argon2id$v=19$m=64,t=1,p=1$yVydNrrs4fNw423CighvPQ$MukT8jzF109WH/lLA7w5tfbB0sR2ZRxlrUPUme7T/LY"
+
+	passwordFile := path.Join(dir, "password")
+	os.WriteFile(passwordFile, []byte(hashed+"\n"), 0o600)
+
+	htpasswdFile := path.Join(dir, "htpasswd")
+	os.WriteFile(htpasswdFile, []byte("imported:"+htpasswdHash+"\n"), 0o600)
+
+	config := NewConfig(SOURCE_FILE, "")
+	err := config.Load([]byte(`
+auth:
+  basic-auth:
+    users:
+      htpasswd-file: ` + htpasswdFile + `
+      users:
+        - username: inline
+          password: '` + inlineHash + `'
+        - username: from-file
+          password-file: ` + passwordFile + `
+redirects:
+  - from: example.com
+    to: https://target.com
+    auth: [users]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		username, password string
+		authorized         bool
+	}{
+		{"inline", "inline-password", true},
+		{"from-file", "file-password", true},
+		{"imported", "htpasswd-password", true},
+		{"imported", "file-password", false},
+		// Unknown users are checked against the hash of the first user, but never accepted
+		{"unknown", "inline-password", false},
+	}
+
+	for _, testCase := range testCases {
+		request, _ := http.NewRequest("GET", "https://example.com", nil)
+		request.SetBasicAuth(testCase.username, testCase.password)
+
+		if got := config.Redirects[0].IsAuthorized(request); got != testCase.authorized {
+			t.Errorf("%s/%s: expected authorized %v, got %v", testCase.username, testCase.password, testCase.authorized, got)
+		}
+	}
+
+	// Test the password file content is not part of the printed config
+	if strings.Contains(config.String(), hashed) {
+		t.Errorf("expected password file content not to be printed")
+	}
+
+	// Test duplicate user between inline users and htpasswd file
+	os.WriteFile(htpasswdFile, []byte("inline:"+htpasswdHash+"\n"), 0o600)
+	err = NewConfig(SOURCE_FILE, "").Load([]byte(`
+auth:
+  basic-auth:
+    users:
+      htpasswd-file: ` + htpasswdFile + `
+      users:
+        - username: inline
+          password: secret
+redirects: []
+`))
+	if err == nil {
+		t.Errorf("expected error on duplicate username, got nil")
+	}
+
+	sha256PasswordFile := path.Join(dir, "sha256-password")
+	os.WriteFile(sha256PasswordFile, []byte("sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b\n"), 0o600)
+
+	// Test invalid and missing passwords
+	invalidConfigs := []string{
+		"password: '$2y$10$invalid'",
+		// Fast unsalted hashes are only accepted for API keys
+		"password: sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b",
+		"password-file: " + sha256PasswordFile,
+		"password-file: " + path.Join(dir, "missing"),
+		"password: secret\n          password-file: " + passwordFile,
+	}
+
+	for _, userConfig := range invalidConfigs {
+		err = NewConfig(SOURCE_FILE, "").Load([]byte(`
+auth:
+  basic-auth:
+    users:
+      users:
+        - username: user
+          ` + userConfig + `
+redirects: []
+`))
+		if err == nil {
+			t.Errorf("expected error for %q, got nil", userConfig)
+		}
+	}
+}
+
+func TestForwardAuthValidation(t *testing.T) {
+	load := func(yamlConfig string) error {
+		return NewConfig(SOURCE_FILE, "").Load([]byte(yamlConfig))
+	}
+
+	// Test happy scenario
+	err := load(`
+auth:
+  forward-auth:
+    sso:
+      address: https://auth.example.com/verify
+redirects:
+  - from: example.com
+    to: https://target.com
+    auth: [sso]
+`)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	invalidConfigs := map[string]string{
+		"invalid address": `
+auth:
+  forward-auth:
+    sso:
+      address: auth.example.com
+redirects: []
+`,
+		"combined with basic auth": `
+auth:
+  basic-auth:
+    users:
+      users:
+        - username: user
+          password: secret
+  forward-auth:
+    sso:
+      address: https://auth.example.com/verify
+redirects:
+  - from: example.com
+    to: https://target.com
+    auth: [users, sso]
+`,
+		"duplicate name": `
+auth:
+  basic-auth:
+    sso:
+      users: []
+  forward-auth:
+    sso:
+      address: https://auth.example.com/verify
+redirects: []
+`,
+	}
+
+	for name, yamlConfig := range invalidConfigs {
+		if err := load(yamlConfig); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestOIDCValidation(t *testing.T) {
+	load := func(yamlConfig string) (*Config, error) {
+		config := NewConfig(SOURCE_FILE, "")
+		return config, config.Load([]byte(yamlConfig))
+	}
+
+	// Test happy scenario and defaults
+	config, err := load(`
+auth:
+  oidc:
+    staff:
+      issuer: https://accounts.example.com
+      client-id: redirector
+redirects:
+  - from: example.com
+    to: https://target.com
+    auth: [staff]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	auth := config.Redirects[0].GetOIDC()
+	if auth == nil || auth.GetName() != "staff" || auth.CallbackPath != "/_redirector/oidc/callback" || auth.SessionDuration != 12*time.Hour {
+		t.Errorf("unexpected OIDC auth: %+v", auth)
+	}
+
+	invalidConfigs := map[string]string{
+		"invalid issuer": `
+auth:
+  oidc:
+    staff:
+      issuer: accounts.example.com
+      client-id: redirector
+redirects: []
+`,
+		"missing client id": `
+auth:
+  oidc:
+    staff:
+      issuer: https://accounts.example.com
+redirects: []
+`,
+		"missing openid scope": `
+auth:
+  oidc:
+    staff:
+      issuer: https://accounts.example.com
+      client-id: redirector
+      scopes: [email]
+redirects: []
+`,
+		"combined with basic auth": `
+auth:
+  basic-auth:
+    users:
+      users:
+        - username: user
+          password: secret
+  oidc:
+    staff:
+      issuer: https://accounts.example.com
+      client-id: redirector
+redirects:
+  - from: example.com
+    to: https://target.com
+    auth: [users, staff]
+`,
+	}
+
+	for name, yamlConfig := range invalidConfigs {
+		if _, err := load(yamlConfig); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestOIDCUserRestrictions(t *testing.T) {
+	open := OIDCSchema{}
+	if !open.IsUserAllowed("sub", "", false, nil) {
+		t.Errorf("expected any user to be allowed without restrictions")
+	}
+
+	auth := OIDCSchema{
+		AllowedEmailDomains: []string{"example.com"},
+		AllowedGroups:       []string{"staff"},
+		AllowedUsers:        []string{"guest@other.com", "sub-123"},
+	}
+
+	testCases := []struct {
+		subject, email string
+		verified       bool
+		groups         []string
+		expected       bool
+	}{
+		{"a", "alice@example.com", true, nil, true},
+		{"a", "alice@EXAMPLE.com", true, nil, true},
+		{"a", "alice@example.com", false, nil, false},
+		{"a", "alice@sub.example.com", true, nil, false},
+		{"a", "bob@other.com", true, []string{"staff"}, true},
+		{"a", "bob@other.com", true, []string{"guests"}, false},
+		{"a", "guest@other.com", true, nil, true},
+		{"a", "guest@other.com", false, nil, false},
+		{"a", "GUEST@other.com", false, []string{"guests"}, false},
+		{"sub-123", "", false, nil, true},
+	}
+
+	for _, testCase := range testCases {
+		if got := auth.IsUserAllowed(testCase.subject, testCase.email, testCase.verified, testCase.groups); got != testCase.expected {
+			t.Errorf("%+v: expected %v, got %v", testCase, testCase.expected, got)
+		}
+	}
+}
+
+func TestJwtAndApiKeyAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	publicKey, _ := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	publicKeyFile := path.Join(dir, "public.pem")
+	os.WriteFile(publicKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}), 0o600)
+
+	keyFile := path.Join(dir, "key")
+	os.WriteFile(keyFile, []byte("file-key\n"), 0o600)
+
+	config := NewConfig(SOURCE_FILE, "")
+	err := config.Load([]byte(`
+auth:
+  basic-auth:
+    users:
+      users:
+        - username: user
+          password: secret
+  jwt:
+    ci:
+      secret: jwt-secret
+      algorithms: [HS256]
+      issuer: ci.example.com
+      audience: redirector
+      claims:
+        role: deployer
+      query-param: token
+    signed:
+      public-key-file: ` + publicKeyFile + `
+      allow-no-expiry: true
+  api-key:
+    keys:
+      query-param: api_key
+      keys:
+        - name: ci
+          # printf 'inline-key' | sha256sum
+          key: sha256:cf58eb88f66c8fb1ac4271e6432580393e6443b744522ad3aa0faaac6790ea86
+        - name: deploy
+          key-file: ` + keyFile + `
+redirects:
+  - from: example.com
+    to: https://target.com
+    auth: [users, ci, signed, keys]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	sign := func(claims jwt.Claims, algorithm string, key any) string {
+		token, _ := jwttest.Sign(claims, algorithm, "", key)
+		return token
+	}
+
+	validClaims := jwt.Claims{"iss": "ci.example.com", "aud": "redirector", "role": []string{"reader", "deployer"}, "exp": time.Now().Add(time.Hour).Unix()}
+	wrongClaims := jwt.Claims{"iss": "ci.example.com", "aud": "redirector", "role": "reader", "exp": time.Now().Add(time.Hour).Unix()}
+	noExpiryClaims := jwt.Claims{"iss": "ci.example.com", "aud": "redirector", "role": "deployer"}
+	expiredClaims := jwt.Claims{"iss": "ci.example.com", "aud": "redirector", "role": "deployer", "exp": time.Now().Add(-time.Hour).Unix()}
+
+	testCases := []struct {
+		name       string
+		prepare    func(*http.Request)
+		authorized bool
+	}{
+		{"no credentials", func(*http.Request) {}, false},
+		{"basic auth", func(req *http.Request) { req.SetBasicAuth("user", "secret") }, true},
+		{"bearer token", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+sign(validClaims, "HS256", []byte("jwt-secret")))
+		}, true},
+		{"token query param", func(req *http.Request) {
+			req.URL.RawQuery = "token=" + sign(validClaims, "HS256", []byte("jwt-secret"))
+		}, true},
+		{"wrong claims", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+sign(wrongClaims, "HS256", []byte("jwt-secret")))
+		}, false},
+		{"expired token", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+sign(expiredClaims, "HS256", []byte("jwt-secret")))
+		}, false},
+		{"token without expiry", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+sign(noExpiryClaims, "HS256", []byte("jwt-secret")))
+		}, false},
+		{"disallowed algorithm", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+sign(validClaims, "HS512", []byte("jwt-secret")))
+		}, false},
+		{"wrong secret", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+sign(validClaims, "HS256", []byte("other")))
+		}, false},
+		{"public key token without expiry", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+sign(jwt.Claims{"sub": "job"}, "ES256", ecKey))
+		}, true},
+		{"hashed api key", func(req *http.Request) { req.Header.Set("X-API-Key", "inline-key") }, true},
+		{"api key from file in query", func(req *http.Request) { req.URL.RawQuery = "api_key=file-key" }, true},
+		{"wrong api key", func(req *http.Request) { req.Header.Set("X-API-Key", "other-key") }, false},
+	}
+
+	for _, testCase := range testCases {
+		request, _ := http.NewRequest("GET", "https://example.com", nil)
+		testCase.prepare(request)
+
+		if got := config.Redirects[0].IsAuthorized(request); got != testCase.authorized {
+			t.Errorf("%s: expected authorized %v, got %v", testCase.name, testCase.authorized, got)
+		}
+	}
+
+	// Test credentials are removed from the request
+	request, _ := http.NewRequest("GET", "https://example.com/?api_key=file-key&token=x&page=2", nil)
+	request.Header.Set("X-API-Key", "inline-key")
+	config.Redirects[0].RemoveCredentials(request)
+
+	if request.URL.RawQuery != "page=2" || request.Header.Get("X-API-Key") != "" {
+		t.Errorf("expected credentials to be removed, got query %q and headers %v", request.URL.RawQuery, request.Header)
+	}
+
+	invalidConfigs := map[string]string{
+		"no jwt key": `
+auth:
+  jwt:
+    ci:
+      issuer: ci.example.com
+redirects: []
+`,
+		"several jwt keys": `
+auth:
+  jwt:
+    ci:
+      secret: secret
+      jwks-file: /tmp/jwks.json
+redirects: []
+`,
+		"unknown algorithm": `
+auth:
+  jwt:
+    ci:
+      secret: secret
+      algorithms: [none]
+redirects: []
+`,
+		"missing jwks file": `
+auth:
+  jwt:
+    ci:
+      jwks-file: /does/not/exist.json
+redirects: []
+`,
+		"api key without key": `
+auth:
+  api-key:
+    keys:
+      keys:
+        - name: ci
+redirects: []
+`,
+		"duplicate api key name": `
+auth:
+  api-key:
+    keys:
+      keys:
+        - name: ci
+          key: a
+        - name: ci
+          key: b
+redirects: []
+`,
+		"invalid api key hash": `
+auth:
+  api-key:
+    keys:
+      keys:
+        - name: ci
+          key: sha256:abc
+redirects: []
+`,
+		"slow api key hash": `
+auth:
+  api-key:
+    keys:
+      keys:
+        - name: ci
+          key: '$2a$04$SUECnRSxbEUzVlZqup6LLeJ0ndFMWIP4S/8Y9paBJuDGVLECGD7e6'
+redirects: []
+`,
+		"duplicate name": `
+auth:
+  jwt:
+    shared:
+      secret: secret
+  api-key:
+    shared:
+      keys: []
+redirects: []
+`,
+	}
+
+	for name, yamlConfig := range invalidConfigs {
+		if err := NewConfig(SOURCE_FILE, "").Load([]byte(yamlConfig)); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestRedirectStatus(t *testing.T) {
+	// Test the status of each redirect is resolved from its own fields first, then the global ones
+	config := NewConfig(SOURCE_FILE, "")
+	err := config.Load([]byte(`
+status: 301
+redirects:
+  - from: a.com
+    to: https://target.com
+  - from: b.com
+    to: https://target.com
+    status: 303
+  - from: c.com
+    to: https://target.com
+    temp-redirect: true
+  - from: d.com
+    to: https://target.com
+    status: 302
+`))
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []struct {
+		status       int
+		tempRedirect bool
+	}{
+		{http.StatusMovedPermanently, false},
+		{http.StatusSeeOther, true},
+		{http.StatusTemporaryRedirect, true},
+		{http.StatusFound, true},
+	}
+
+	for i, redirect := range config.Redirects {
+		if redirect.GetStatusCode() != expected[i].status || *redirect.TempRedirect != expected[i].tempRedirect {
+			t.Errorf("#%d: expected status %d, got %d (temp-redirect: %v)", i, expected[i].status, redirect.GetStatusCode(), *redirect.TempRedirect)
+		}
+	}
+
+	// Test the global temp-redirect still applies when no status is given
+	config = NewConfig(SOURCE_FILE, "")
+	err = config.Load([]byte(`
+temp-redirect: false
+redirects:
+  - from: a.com
+    to: https://target.com
+`))
+
+	if err != nil || config.Redirects[0].GetStatusCode() != http.StatusPermanentRedirect {
+		t.Errorf("expected permanent redirect, got %+v (%v)", config.Redirects, err)
+	}
+
+	// Test invalid and conflicting statuses
+	invalidConfigs := map[string]string{
+		"status: 200\nredirects:\n  - from: a.com\n    to: https://target.com\n":                              "invalid-status",
+		"status: 301\ntemp-redirect: true\nredirects:\n  - from: a.com\n    to: https://target.com\n":         "conflicting-fields",
+		"redirects:\n  - from: a.com\n    to: https://target.com\n    status: 304\n":                          "invalid-status",
+		"redirects:\n  - from: a.com\n    to: https://target.com\n    status: 301\n    temp-redirect: true\n": "conflicting-fields",
+		"redirects:\n  - from: a.com\n    to: http://backend.internal\n    status: 301\n    mode: proxy\n":    "conflicting-fields",
+	}
+
+	for yamlConfig, code := range invalidConfigs {
+		diagnostics := Validate([]byte(yamlConfig))
+		if !diagnostics.HasErrors() || diagnostics[0].Code != code {
+			t.Errorf("%q: expected %s error, got %+v", yamlConfig, code, diagnostics)
+		}
+	}
+}
+
+func TestAuthProtectionClientIP(t *testing.T) {
+	config := NewConfig(SOURCE_FILE, "")
+	err := config.Load([]byte(`
+auth:
+  protection:
+    trusted-proxies: [10.0.0.0/8, 192.168.1.1]
+redirects:
+  - from: example.com
+    to: https://target.com
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := []struct {
+		remoteAddr, forwardedFor, expected string
+	}{
+		{"1.1.1.1:1234", "2.2.2.2", "1.1.1.1"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+		{"10.0.0.1:1234", "2.2.2.2", "2.2.2.2"},
+		{"10.0.0.1:1234", "6.6.6.6, 2.2.2.2, 192.168.1.1", "2.2.2.2"},
+		{"192.168.1.1:1234", "10.0.0.2", "10.0.0.2"},
+	}
+
+	for _, testCase := range testCases {
+		req, _ := http.NewRequest("GET", "https://example.com", nil)
+		req.RemoteAddr = testCase.remoteAddr
+		if testCase.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", testCase.forwardedFor)
+		}
+
+		if got := config.Auth.Protection.GetClientIP(req); got != testCase.expected {
+			t.Errorf("%+v: expected %q, got %q", testCase, testCase.expected, got)
+		}
+	}
+
+	diagnostics := Validate([]byte("auth:\n  protection:\n    trusted-proxies: [10.0.0.0/33]\n"))
+	if !diagnostics.HasErrors() || diagnostics[0].Code != "invalid-trusted-proxy" {
+		t.Errorf("expected invalid trusted proxy error, got %+v", diagnostics)
+	}
+}
@@ -1,5 +1,7 @@
 package models
 
+import "net/http"
+
 const (
 	SOURCE_STDIN = "@source:stdin"
 	SOURCE_FILE  = "@source:file"
@@ -10,3 +12,23 @@ const (
 	REFRESH_ON_HIT  = "hit"
 	REFRESH_ON_MISS = "miss"
 )
+
+const (
+	PATH_MATCH_EXACT  = "exact"
+	PATH_MATCH_PREFIX = "prefix"
+	PATH_MATCH_GLOB   = "glob"
+)
+
+const (
+	MODE_REDIRECT = "redirect"
+	MODE_PROXY    = "proxy"
+)
+
+// Status codes a redirect can respond with
+var REDIRECT_STATUSES = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusSeeOther,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
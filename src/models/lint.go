@@ -0,0 +1,289 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/AmrSaber/redirector/src/utils"
+)
+
+// Label used in place of wildcards when building sample requests for a redirect
+const lintSampleLabel = "sample"
+
+// Adds a warning diagnostic
+func (diagnostics *Diagnostics) warn(code string, path []any, message string) {
+	*diagnostics = append(*diagnostics, NewDiagnostic(SEVERITY_WARNING, code, path, message))
+}
+
+// Looks for parts of a valid config that have no effect or misbehave, such as rules that can never be matched
+func (c Config) lint() Diagnostics {
+	diagnostics := Diagnostics{}
+
+	diagnostics = append(diagnostics, c.lintUnreachableRedirects()...)
+	diagnostics = append(diagnostics, c.lintRedirectLoops()...)
+	diagnostics = append(diagnostics, c.lintUnusedAuths()...)
+	diagnostics = append(diagnostics, c.lintRefreshDomains()...)
+
+	return diagnostics
+}
+
+// Finds redirects that are never matched because an earlier redirect always takes precedence over them
+func (c Config) lintUnreachableRedirects() Diagnostics {
+	diagnostics := Diagnostics{}
+
+	for j, later := range c.Redirects {
+		for i, earlier := range c.Redirects[:j] {
+			if later.FromRegex != "" || earlier.FromRegex != "" {
+				if later.FromRegex != "" && later.FromRegex == earlier.FromRegex {
+					diagnostics.warn("duplicate-rule", fieldPath("redirects", j, "from-regex"), fmt.Sprintf(`Duplicate "from-regex" %q [#%d], redirect #%d has the same one and will always be matched instead`, later.FromRegex, j, i))
+					break
+				}
+
+				continue
+			}
+
+			// Paths only tie when they are the same rule, otherwise the more specific path is matched regardless of the order
+			if !earlier.hasSamePathRule(later) {
+				continue
+			}
+
+			if earlier.From == later.From {
+				diagnostics.warn("duplicate-rule", fieldPath("redirects", j, "from"), fmt.Sprintf(`Duplicate "from" %q [#%d], redirect #%d has the same "from" and "path" and will always be matched instead`, later.From, j, i))
+				break
+			}
+
+			// Exact domains are matched before wildcard ones, so only a wildcard can shadow another wildcard
+			if strings.Contains(later.From, "*") && domainPatternCovers(earlier.From, later.From) {
+				diagnostics.warn("shadowed-rule", fieldPath("redirects", j, "from"), fmt.Sprintf(`Redirect #%d (%s) is shadowed by redirect #%d (%s) and will never be matched`, j, later.GetName(), i, earlier.GetName()))
+				break
+			}
+		}
+	}
+
+	return diagnostics
+}
+
+func (redirect Redirect) hasSamePathRule(other Redirect) bool {
+	if redirect.Path == "" || other.Path == "" {
+		return redirect.Path == other.Path
+	}
+
+	return redirect.getPathMatch() == other.getPathMatch() && normalizePath(redirect.Path) == normalizePath(other.Path)
+}
+
+// Finds redirects that send requests back to themselves, directly or through other redirects
+func (c Config) lintRedirectLoops() Diagnostics {
+	diagnostics := Diagnostics{}
+
+	// Redirect each redirect sends its sample request to, -1 if none
+	next := make([]int, len(c.Redirects))
+	for i, r := range c.Redirects {
+		next[i] = c.matchRedirectTarget(r)
+	}
+
+	reported := make([]bool, len(c.Redirects))
+	for i := range c.Redirects {
+		if next[i] == i {
+			diagnostics.warn("self-redirect", fieldPath("redirects", i, "to"), fmt.Sprintf(`Redirect #%d (%s) redirects to itself`, i, c.Redirects[i].GetName()))
+			reported[i] = true
+			continue
+		}
+
+		// Follow the chain, a loop back to the start is reported once at its first redirect
+		chain := []int{i}
+		for current := next[i]; current != -1 && !slices.Contains(chain, current); current = next[current] {
+			chain = append(chain, current)
+		}
+
+		last := chain[len(chain)-1]
+		if len(chain) > 1 && next[last] == i && !reported[i] {
+			steps := make([]string, 0, len(chain)+1)
+			for _, index := range append(chain, i) {
+				steps = append(steps, fmt.Sprintf("#%d", index))
+				reported[index] = true
+			}
+
+			diagnostics.warn("redirect-loop", fieldPath("redirects", i, "to"), fmt.Sprintf(`Redirect loop: %s`, strings.Join(steps, " -> ")))
+		}
+	}
+
+	return diagnostics
+}
+
+// Returns the index of the redirect matching where the given redirect sends a sample request, or -1 if it can't be known
+// Proxies are skipped as they send requests to the upstream server and not to the client
+func (c Config) matchRedirectTarget(redirect Redirect) int {
+	if redirect.FromRegex != "" || redirect.Mode == MODE_PROXY {
+		return -1
+	}
+
+	requestPath := "/"
+	if redirect.Path != "" {
+		if redirect.getPathMatch() == PATH_MATCH_GLOB && strings.ContainsAny(redirect.Path, `*?[\`) {
+			return -1
+		}
+
+		requestPath = redirect.Path
+	}
+
+	host := strings.NewReplacer("**", lintSampleLabel, "*", lintSampleLabel).Replace(redirect.From)
+	request := &http.Request{Host: host, URL: &url.URL{Path: requestPath}}
+
+	target, err := url.Parse(redirect.ResolvePath(request))
+	if err != nil {
+		return -1
+	}
+
+	return matchRedirectIndex(c.Redirects, strings.ToLower(target.Hostname()), target.Path)
+}
+
+// Returns the index of the redirect matching the host and path, or -1 if none matched
+// Follows the same precedence as the redirects matcher: exact domains, then wildcards, then regex redirects
+func matchRedirectIndex(redirects []Redirect, host, requestPath string) int {
+	for _, wildcard := range []bool{false, true} {
+		bestIndex, bestSpecificity := -1, -1
+
+		for i, r := range redirects {
+			if r.FromRegex != "" || strings.Contains(r.From, "*") != wildcard || !domainPatternCovers(r.From, host) {
+				continue
+			}
+
+			if isMatch, specificity := r.MatchPath(requestPath); isMatch && specificity > bestSpecificity {
+				bestIndex, bestSpecificity = i, specificity
+			}
+		}
+
+		if bestIndex != -1 {
+			return bestIndex
+		}
+	}
+
+	for i, r := range redirects {
+		if r.MatchRegex(host, requestPath) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Finds auth schemas that are not used by any redirect
+func (c Config) lintUnusedAuths() Diagnostics {
+	diagnostics := Diagnostics{}
+	if c.Auth == nil {
+		return diagnostics
+	}
+
+	used := map[string]bool{}
+	for _, r := range c.Redirects {
+		for _, name := range r.AuthNames {
+			used[name] = true
+		}
+	}
+
+	authTypes := []struct {
+		key   string
+		names []string
+	}{
+		{"basic-auth", utils.GetMapKeys(c.Auth.BasicAuth)},
+		{"forward-auth", utils.GetMapKeys(c.Auth.ForwardAuth)},
+		{"oidc", utils.GetMapKeys(c.Auth.OIDC)},
+		{"jwt", utils.GetMapKeys(c.Auth.Jwt)},
+		{"api-key", utils.GetMapKeys(c.Auth.ApiKey)},
+	}
+
+	for _, authType := range authTypes {
+		slices.Sort(authType.names)
+
+		for _, name := range authType.names {
+			if !used[name] {
+				diagnostics.warn("unused-auth", fieldPath("auth", authType.key, name), fmt.Sprintf(`Auth %q is not used by any redirect [@%s %q]`, name, authType.key, name))
+			}
+		}
+	}
+
+	return diagnostics
+}
+
+// Finds refresh domains set to refresh on hit that can never be hit
+func (c Config) lintRefreshDomains() Diagnostics {
+	diagnostics := Diagnostics{}
+	if c.UrlConfigRefresh == nil {
+		return diagnostics
+	}
+
+	// Regex redirects can match any domain
+	if slices.ContainsFunc(c.Redirects, func(r Redirect) bool { return r.FromRegex != "" }) {
+		return diagnostics
+	}
+
+	for i, d := range c.UrlConfigRefresh.RefreshDomains {
+		if d.RefreshOn != REFRESH_ON_HIT {
+			continue
+		}
+
+		if !slices.ContainsFunc(c.Redirects, func(r Redirect) bool { return domainPatternsOverlap(r.From, d.Domain) }) {
+			diagnostics.warn("unmatched-refresh-domain", fieldPath("url-config-refresh", "refresh-domains", i, "domain"), fmt.Sprintf(`Refresh domain %q does not match any redirect, it will never be hit [#%d]`, d.Domain, i))
+		}
+	}
+
+	return diagnostics
+}
+
+// Checks whether every domain matched by pattern b is also matched by pattern a, both can have wildcards
+func domainPatternCovers(a, b string) bool {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+
+	aMultiIndex := slices.Index(aParts, "**")
+	if aMultiIndex == -1 {
+		return len(aParts) == len(bParts) && labelsCover(aParts, bParts)
+	}
+
+	before, after := aParts[:aMultiIndex], aParts[aMultiIndex+1:]
+
+	// "**" of b must fall in the part matched by "**" of a, which matches at least one label
+	bMultiIndex := slices.Index(bParts, "**")
+	if bMultiIndex == -1 {
+		if len(bParts) <= len(before)+len(after) {
+			return false
+		}
+	} else if bMultiIndex < len(before) || len(bParts)-bMultiIndex-1 < len(after) {
+		return false
+	}
+
+	return labelsCover(before, bParts[:len(before)]) && labelsCover(after, bParts[len(bParts)-len(after):])
+}
+
+// Checks whether each label of a matches the label of b at the same position
+func labelsCover(a, b []string) bool {
+	for i := range a {
+		if b[i] == "**" || (a[i] != "*" && a[i] != b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks whether some domain can match both patterns, patterns with "**" are assumed to overlap
+func domainPatternsOverlap(a, b string) bool {
+	if strings.Contains(a, "**") || strings.Contains(b, "**") {
+		return true
+	}
+
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	if len(aParts) != len(bParts) {
+		return false
+	}
+
+	for i := range aParts {
+		if aParts[i] != "*" && bParts[i] != "*" && aParts[i] != bParts[i] {
+			return false
+		}
+	}
+
+	return true
+}
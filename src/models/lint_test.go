@@ -0,0 +1,152 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDomainPatternCovers(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"example.com", "example.com", true},
+		{"*.example.com", "a.example.com", true},
+		{"*.example.com", "*.example.com", true},
+		{"*.example.com", "a.b.example.com", false},
+		{"a.example.com", "*.example.com", false},
+		{"*.*.com", "*.example.com", true},
+		{"**.example.com", "a.b.example.com", true},
+		{"**.example.com", "*.example.com", true},
+		{"**.example.com", "example.com", false},
+		{"*.example.com", "**.example.com", false},
+		{"a.**.com", "a.*.b.com", true},
+		{"a.**.com", "**.b.com", false},
+	}
+
+	for _, testCase := range testCases {
+		if got := domainPatternCovers(testCase.a, testCase.b); got != testCase.expected {
+			t.Errorf("%q covers %q: got %v, expected %v", testCase.a, testCase.b, got, testCase.expected)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	lintCodes := func(yamlConfig string) []string {
+		diagnostics := Validate([]byte(yamlConfig))
+		if diagnostics.HasErrors() {
+			t.Fatalf("unexpected errors: %+v", diagnostics)
+		}
+
+		codes := []string{}
+		for _, diagnostic := range diagnostics {
+			if diagnostic.Severity != SEVERITY_WARNING {
+				t.Errorf("unexpected severity: %+v", diagnostic)
+			}
+
+			codes = append(codes, diagnostic.Code)
+		}
+
+		return codes
+	}
+
+	// Test clean config has no warnings
+	codes := lintCodes(`
+auth:
+  basic-auth:
+    used:
+      users:
+        - username: user
+          password: password
+redirects:
+  - from: example.com
+    to: https://target.com
+    auth: [used]
+  - from: example.com
+    path: /docs
+    to: https://docs.target.com
+  - from: "*.example.com"
+    to: https://*.target.com
+  - from: a.example.com
+    to: https://example.com
+url-config-refresh:
+  refresh-domains:
+    - domain: "*.example.com"
+      refresh-on: hit
+`)
+
+	if len(codes) != 0 {
+		t.Errorf("unexpected warnings: %v", codes)
+	}
+
+	testCases := map[string]string{
+		"duplicate-rule": `
+redirects:
+  - from: example.com
+    path: /docs/
+    to: https://target.com
+  - from: example.com
+    path: /docs
+    to: https://other.com
+`,
+		"shadowed-rule": `
+redirects:
+  - from: "**.example.com"
+    to: https://target.com
+  - from: "*.example.com"
+    to: https://other.com
+`,
+		"self-redirect": `
+redirects:
+  - from: example.com
+    to: https://example.com/home
+`,
+		"redirect-loop": `
+redirects:
+  - from: a.com
+    to: https://b.com
+  - from: b.com
+    path: /
+    path-match: exact
+    to: https://a.com
+`,
+		"unused-auth": `
+auth:
+  api-key:
+    unused:
+      keys:
+        - name: client
+          key: secret-key
+redirects:
+  - from: example.com
+    to: https://target.com
+`,
+		"unmatched-refresh-domain": `
+redirects:
+  - from: example.com
+    to: https://target.com
+url-config-refresh:
+  refresh-domains:
+    - domain: "*.other.com"
+      refresh-on: hit
+`,
+	}
+
+	for code, yamlConfig := range testCases {
+		if codes := lintCodes(yamlConfig); !slices.Equal(codes, []string{code}) {
+			t.Errorf("expected %s warning, got %v", code, codes)
+		}
+	}
+
+	// Test proxies are not reported as loops
+	codes = lintCodes(`
+redirects:
+  - from: example.com
+    to: https://example.com
+    mode: proxy
+`)
+
+	if len(codes) != 0 {
+		t.Errorf("unexpected warnings: %v", codes)
+	}
+}
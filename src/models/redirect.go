@@ -1,66 +1,303 @@
 package models
 
 import (
-	"crypto/sha256"
-	"crypto/subtle"
+	"math"
 	"net/http"
 	"net/url"
+	"path"
+	"regexp"
+	"slices"
 	"strings"
+
+	"github.com/AmrSaber/redirector/src/lib/passwords"
+	"github.com/AmrSaber/redirector/src/utils"
 )
 
 type Redirect struct {
-	From         string     `yaml:"from"`
-	To           string     `yaml:"to"`
-	PreservePath bool       `yaml:"preserve-path"`
-	TempRedirect *bool      `yaml:"temp-redirect"`
-	AuthNames    []string   `yaml:"auth,omitempty"`
-	ActualAuths  AuthSchema `yaml:"-"`
+	Name         string     `yaml:"name,omitempty" json:"name,omitempty"`
+	From         string     `yaml:"from,omitempty" json:"from,omitempty"`
+	FromRegex    string     `yaml:"from-regex,omitempty" json:"from-regex,omitempty"`
+	Path         string     `yaml:"path,omitempty" json:"path,omitempty"`
+	PathMatch    string     `yaml:"path-match,omitempty" json:"path-match,omitempty"`
+	To           string     `yaml:"to" json:"to"`
+	PreservePath bool       `yaml:"preserve-path" json:"preserve-path"`
+	Mode         string     `yaml:"mode,omitempty" json:"mode,omitempty"`
+	TempRedirect *bool      `yaml:"temp-redirect" json:"temp-redirect"`
+	Status       int        `yaml:"status,omitempty" json:"status,omitempty"`
+	AuthNames    []string   `yaml:"auth,omitempty" json:"auth,omitempty"`
+	ActualAuths  AuthSchema `yaml:"-" json:"-"`
+
+	index     int
+	fromRegex *regexp.Regexp
 }
 
 func (redirect Redirect) ResolvePath(request *http.Request) string {
+	if redirect.FromRegex != "" {
+		return redirect.resolveRegexPath(request)
+	}
+
 	toUrl, _ := url.Parse(redirect.To)
 
 	if strings.Contains(toUrl.Host, "*") {
 		toSections := strings.Split(toUrl.Host, ".")
-		requestSections := strings.Split(request.Host, ".")
+		fromSections := strings.Split(redirect.From, ".")
+		requestSections := strings.Split(utils.StripPort(request.Host), ".")
 
-		// Substitute every * in to with corresponding section in request
+		// "to" and "from" have "**" at the same position (if any), sections after it are aligned with the end of the request
+		multiIndex := slices.Index(fromSections, "**")
+		multiLength := len(requestSections) - len(fromSections) + 1
+
+		// Substitute every * in to with corresponding section in request, and ** with all the sections it matched
+		resolvedSections := make([]string, 0, len(requestSections))
 		for i, section := range toSections {
-			if section != "*" {
-				continue
-			}
+			switch {
+			case section == "**":
+				resolvedSections = append(resolvedSections, requestSections[i:i+multiLength]...)
+
+			case section == "*" && multiIndex != -1 && i > multiIndex:
+				resolvedSections = append(resolvedSections, requestSections[i+multiLength-1])
+
+			case section == "*":
+				resolvedSections = append(resolvedSections, requestSections[i])
 
-			toSections[i] = requestSections[i]
+			default:
+				resolvedSections = append(resolvedSections, section)
+			}
 		}
 
-		toUrl.Host = strings.Join(toSections, ".")
+		toUrl.Host = strings.Join(resolvedSections, ".")
 	}
 
-	if redirect.PreservePath {
-		toUrl.Path = request.URL.Path
+	// Proxies always forward the request path
+	if redirect.PreservePath || redirect.Mode == MODE_PROXY {
+		if redirect.Path != "" && redirect.getPathMatch() == PATH_MATCH_PREFIX {
+			// Append whatever remains after the matched prefix to the target path
+			prefix := strings.TrimSuffix(redirect.Path, "/")
+			remainder := strings.TrimPrefix(request.URL.Path, prefix)
+			toUrl.Path = strings.TrimSuffix(toUrl.Path, "/") + remainder
+		} else {
+			toUrl.Path = request.URL.Path
+		}
 	}
 
 	return toUrl.String()
 }
 
+// Returns a readable identifier of the redirect, used in logs and metrics
+func (redirect Redirect) GetName() string {
+	if redirect.Name != "" {
+		return redirect.Name
+	}
+
+	if redirect.FromRegex != "" {
+		return redirect.FromRegex
+	}
+
+	return redirect.From + redirect.Path
+}
+
+// Returns the position of the redirect in the config
+func (redirect Redirect) GetIndex() int {
+	return redirect.index
+}
+
+// Checks whether the given request path matches the redirect's path rule, and returns how specific the match is.
+// Redirects without a path rule match any path with the lowest specificity.
+func (redirect Redirect) MatchPath(requestPath string) (bool, int) {
+	if redirect.Path == "" {
+		return true, 0
+	}
+
+	requestPath = normalizePath(requestPath)
+
+	switch redirect.getPathMatch() {
+	case PATH_MATCH_EXACT:
+		return requestPath == normalizePath(redirect.Path), math.MaxInt
+
+	case PATH_MATCH_PREFIX:
+		prefix := strings.TrimSuffix(redirect.Path, "/")
+		isMatch := requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+
+		// Prefixes beat globs with the same literal length
+		return isMatch, 2*len(prefix) + 2
+
+	case PATH_MATCH_GLOB:
+		isMatch, _ := path.Match(redirect.Path, requestPath)
+		return isMatch, 2*globLiteralLength(redirect.Path) + 1
+	}
+
+	return false, 0
+}
+
+// Checks whether the given host and path match the redirect's "from-regex"
+func (redirect Redirect) MatchRegex(host, requestPath string) bool {
+	if redirect.FromRegex == "" {
+		return false
+	}
+
+	return redirect.getFromRegex().MatchString(host + requestPath)
+}
+
+// Substitutes the captures of "from-regex" into "to"
+func (redirect Redirect) resolveRegexPath(request *http.Request) string {
+	fromRegex := redirect.getFromRegex()
+	subject := utils.StripPort(request.Host) + request.URL.Path
+
+	match := fromRegex.FindStringSubmatchIndex(subject)
+	if match == nil {
+		return redirect.To
+	}
+
+	return string(fromRegex.ExpandString(nil, redirect.To, subject, match))
+}
+
+func (redirect Redirect) getFromRegex() *regexp.Regexp {
+	if redirect.fromRegex != nil {
+		return redirect.fromRegex
+	}
+
+	fromRegex, _ := compileFromRegex(redirect.FromRegex)
+	return fromRegex
+}
+
+// Returns the status code of the redirect response
+func (redirect Redirect) GetStatusCode() int {
+	if redirect.Status != 0 {
+		return redirect.Status
+	}
+
+	return getTempRedirectStatus(redirect.TempRedirect != nil && *redirect.TempRedirect)
+}
+
+// Returns the status code that "temp-redirect" is a shorthand for
+func getTempRedirectStatus(tempRedirect bool) int {
+	if tempRedirect {
+		return http.StatusTemporaryRedirect
+	}
+
+	return http.StatusPermanentRedirect
+}
+
+func isTemporaryStatus(status int) bool {
+	return status == http.StatusFound || status == http.StatusSeeOther || status == http.StatusTemporaryRedirect
+}
+
+func redirectStatusesList() string {
+	return strings.Join(utils.ToStringSlice(REDIRECT_STATUSES), ", ")
+}
+
+func (redirect Redirect) getPathMatch() string {
+	if redirect.PathMatch == "" {
+		return PATH_MATCH_PREFIX
+	}
+
+	return redirect.PathMatch
+}
+
 func (redirect Redirect) GetBasicAuthRealm() string {
-	if len(redirect.AuthNames) == 0 {
-		return ""
+	for _, authName := range redirect.AuthNames {
+		if auth, ok := redirect.ActualAuths.BasicAuth[authName]; ok {
+			return auth.Realm
+		}
+	}
+
+	return ""
+}
+
+// Returns the forward auth of the redirect, or nil if it does not use forward auth
+// Forward auth cannot be combined with other auths, so there is at most one
+func (redirect Redirect) GetForwardAuth() *ForwardAuthSchema {
+	for _, auth := range redirect.ActualAuths.ForwardAuth {
+		return auth
 	}
 
-	authName := redirect.AuthNames[0]
-	return redirect.ActualAuths.BasicAuth[authName].Realm
+	return nil
 }
 
+// Returns the OIDC auth of the redirect, or nil if it does not use OIDC
+// OIDC cannot be combined with other auths, so there is at most one
+func (redirect Redirect) GetOIDC() *OIDCSchema {
+	for _, auth := range redirect.ActualAuths.OIDC {
+		return auth
+	}
+
+	return nil
+}
+
+// Checks the credentials of the request against the auths of the redirect, the request is authorized if any auth accepts it
+// Forward auth and OIDC require calls to external endpoints, so they are not checked here
 func (redirect Redirect) IsAuthorized(req *http.Request) bool {
+	auths := redirect.ActualAuths
+	if len(auths.BasicAuth) == 0 && len(auths.Jwt) == 0 && len(auths.ApiKey) == 0 {
+		return true
+	}
+
 	// Validate basic auth
-	if len(redirect.ActualAuths.BasicAuth) > 0 {
-		return redirect.authorizeBasicAuth(req)
+	if len(auths.BasicAuth) > 0 && redirect.authorizeBasicAuth(req) {
+		return true
 	}
 
-	// More kinds of auth to be added here...
+	for _, authName := range redirect.AuthNames {
+		if auth, ok := auths.Jwt[authName]; ok && auth.IsAuthorized(req) {
+			return true
+		}
 
-	return true
+		if auth, ok := auths.ApiKey[authName]; ok && auth.FindMatchingKey(req) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Checks whether the request carries credentials for any of the auths of the redirect
+// Requests without credentials are only prompting for them, so they are not counted as failed attempts
+func (redirect Redirect) HasCredentials(req *http.Request) bool {
+	if _, _, ok := req.BasicAuth(); ok && len(redirect.ActualAuths.BasicAuth) > 0 {
+		return true
+	}
+
+	for _, auth := range redirect.ActualAuths.Jwt {
+		if auth.GetToken(req) != "" {
+			return true
+		}
+	}
+
+	for _, auth := range redirect.ActualAuths.ApiKey {
+		if auth.GetRequestKey(req) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Removes the API keys and tokens of the request that are sent in headers or query parameters
+// so that they are not logged or forwarded, the Authorization header is handled by the proxy
+func (redirect Redirect) RemoveCredentials(req *http.Request) {
+	queryParams := []string{}
+
+	for _, auth := range redirect.ActualAuths.ApiKey {
+		req.Header.Del(auth.Header)
+		queryParams = append(queryParams, auth.QueryParam)
+	}
+
+	for _, auth := range redirect.ActualAuths.Jwt {
+		queryParams = append(queryParams, auth.QueryParam)
+	}
+
+	query := req.URL.Query()
+	removed := false
+	for _, param := range queryParams {
+		if param != "" && query.Has(param) {
+			query.Del(param)
+			removed = true
+		}
+	}
+
+	if removed {
+		req.URL.RawQuery = query.Encode()
+	}
 }
 
 func (redirect Redirect) authorizeBasicAuth(req *http.Request) bool {
@@ -72,16 +309,23 @@ func (redirect Redirect) authorizeBasicAuth(req *http.Request) bool {
 	// Find the matching auth block based on user
 	matchingBasicAuth := redirect.findMatchingBasicAuth(req)
 	if matchingBasicAuth == nil {
+		// Unknown users are checked against the hash of a known user and always rejected,
+		// so that slow hashes do not reveal which usernames exist by the response time
+		for _, authName := range redirect.AuthNames {
+			if auth, ok := redirect.ActualAuths.BasicAuth[authName]; ok {
+				if password := auth.getFirstPassword(); password != "" {
+					_ = passwords.Compare(password, reqPassword)
+					break
+				}
+			}
+		}
+
 		return false
 	}
 
 	userConfig := matchingBasicAuth.FindMatchingUser(reqUsername)
 
-	// Hashes are used to perform const-time password check
-	passwordHash := sha256.Sum256([]byte(reqPassword))
-	expectedPasswordHash := sha256.Sum256([]byte(userConfig.Password))
-
-	return (subtle.ConstantTimeCompare(passwordHash[:], expectedPasswordHash[:]) == 1)
+	return passwords.Compare(userConfig.GetPassword(), reqPassword)
 }
 
 func (redirect Redirect) findMatchingBasicAuth(req *http.Request) *BasicAuthSchema {
@@ -94,14 +338,49 @@ func (redirect Redirect) findMatchingBasicAuth(req *http.Request) *BasicAuthSche
 	// so that the last occurrence of the username takes precedence
 	for i := len(redirect.AuthNames) - 1; i >= 0; i-- {
 		authName := redirect.AuthNames[i]
-		auth := redirect.ActualAuths.BasicAuth[authName]
+		auth, ok := redirect.ActualAuths.BasicAuth[authName]
 
-		for _, userConfig := range auth.Users {
-			if userConfig.Username == reqUsername {
-				return auth
-			}
+		if ok && auth.FindMatchingUser(reqUsername) != nil {
+			return auth
 		}
 	}
 
 	return nil
 }
+
+// Trims trailing slashes from the path, treating empty path as root
+func normalizePath(p string) string {
+	p = strings.TrimRight(p, "/")
+	if p == "" {
+		return "/"
+	}
+
+	return p
+}
+
+// Counts the characters of a glob pattern that match literally, a character class counts as one character
+func globLiteralLength(pattern string) int {
+	length := 0
+	inClass := false
+
+	for _, char := range pattern {
+		switch {
+		case inClass:
+			if char == ']' {
+				inClass = false
+			}
+		case char == '[':
+			inClass = true
+			length++
+		case char != '*' && char != '?':
+			length++
+		}
+	}
+
+	return length
+}
+
+// Compiles "from-regex" anchored to match the whole host and path
+func compileFromRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
@@ -47,3 +47,84 @@ func TestResolveDomain(t *testing.T) {
 		t.Errorf("got %q, expected %q", got, expected)
 	}
 }
+
+func TestResolvePrefixPath(t *testing.T) {
+	redirect := Redirect{
+		From:         "go.amr-saber.com",
+		Path:         "/docs",
+		PathMatch:    PATH_MATCH_PREFIX,
+		To:           "https://docs.amrsaber.io/v2",
+		PreservePath: true,
+	}
+
+	testCases := map[string]string{
+		"https://go.amr-saber.com/docs":             "https://docs.amrsaber.io/v2",
+		"https://go.amr-saber.com/docs/":            "https://docs.amrsaber.io/v2/",
+		"https://go.amr-saber.com/docs/intro/start": "https://docs.amrsaber.io/v2/intro/start",
+	}
+
+	for requestUrl, expected := range testCases {
+		request, _ := http.NewRequest("GET", requestUrl, nil)
+		got := redirect.ResolvePath(request)
+		if got != expected {
+			t.Errorf("got %q, expected %q", got, expected)
+		}
+	}
+
+	// Test exact path preserves the whole path
+	redirect = Redirect{
+		From:         "go.amr-saber.com",
+		Path:         "/docs",
+		PathMatch:    PATH_MATCH_EXACT,
+		To:           "https://docs.amrsaber.io",
+		PreservePath: true,
+	}
+
+	request, _ := http.NewRequest("GET", "https://go.amr-saber.com/docs", nil)
+	got := redirect.ResolvePath(request)
+	expected := "https://docs.amrsaber.io/docs"
+	if got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestResolveRegexPath(t *testing.T) {
+	redirect := Redirect{
+		FromRegex: `(?P<sub>\w+)\.amr-saber\.com/posts/(\d+)/(?P<slug>[^/]+)`,
+		To:        "https://${sub}.amrsaber.io/blog/${slug}?id=$2",
+	}
+
+	request, _ := http.NewRequest("GET", "https://blog.amr-saber.com:8080/posts/42/hello-world", nil)
+	got := redirect.ResolvePath(request)
+	expected := "https://blog.amrsaber.io/blog/hello-world?id=42"
+	if got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestResolveMultiLabelWildcard(t *testing.T) {
+	redirect := Redirect{
+		From: "**.amr-saber.com",
+		To:   "https://**.amrsaber.io",
+	}
+
+	request, _ := http.NewRequest("GET", "https://a.b.amr-saber.com", nil)
+	got := redirect.ResolvePath(request)
+	expected := "https://a.b.amrsaber.io"
+	if got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+
+	// Test single wildcards around "**"
+	redirect = Redirect{
+		From: "*.**.amr-saber.*",
+		To:   "https://*.**.amrsaber.*",
+	}
+
+	request, _ = http.NewRequest("GET", "https://x.a.b.amr-saber.com:8080", nil)
+	got = redirect.ResolvePath(request)
+	expected = "https://x.a.b.amrsaber.com"
+	if got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
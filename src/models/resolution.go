@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Result of resolving a URL against the redirection rules without serving it, as reported by the resolve command
+type Resolution struct {
+	URL     string `yaml:"url" json:"url"`
+	Matched bool   `yaml:"matched" json:"matched"`
+
+	// Index and name of the matched redirect
+	RuleIndex *int   `yaml:"rule-index,omitempty" json:"rule-index,omitempty"`
+	RuleName  string `yaml:"rule-name,omitempty" json:"rule-name,omitempty"`
+	Mode      string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Names of the auths required by the redirect, and whether the given credentials are accepted by any of them
+	Auth       []string `yaml:"auth,omitempty" json:"auth,omitempty"`
+	Authorized bool     `yaml:"authorized" json:"authorized"`
+
+	Target string `yaml:"target,omitempty" json:"target,omitempty"`
+
+	// Status code the server responds with, 0 if it's decided by another server (proxies and forward auth)
+	Status int `yaml:"status" json:"status"`
+
+	// Explains the result when the status alone does not
+	Note string `yaml:"note,omitempty" json:"note,omitempty"`
+}
+
+// Returns where the request ends: its target if it's passed on, otherwise the status code the server responds with
+func (resolution Resolution) GetOutcome() string {
+	if resolution.Target != "" && (resolution.Authorized || resolution.Status == 0) {
+		return resolution.Target
+	}
+
+	return strconv.Itoa(resolution.Status)
+}
+
+// URL and its expected outcome, checked against the redirects whenever the config is loaded
+type RedirectTest struct {
+	Name string `yaml:"name,omitempty"`
+	URL  string `yaml:"url"`
+
+	// Headers sent with the URL, such as credentials
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// Expected target, the target of unauthorized or unmatched requests is their status code, e.g. 401
+	Target string `yaml:"target,omitempty"`
+
+	// Expected status code, proxied requests have no status so they are only checked by target
+	Status int `yaml:"status,omitempty"`
+}
+
+// Returns a readable identifier of the test
+func (test RedirectTest) GetName() string {
+	if test.Name != "" {
+		return test.Name
+	}
+
+	return test.URL
+}
+
+// Checks the resolution of the test URL against the expectations, returns why it failed or empty string if it passed
+func (test RedirectTest) Check(resolution Resolution) string {
+	if test.Target != "" && resolution.GetOutcome() != test.Target {
+		return fmt.Sprintf("expected target %q, got %q", test.Target, resolution.GetOutcome())
+	}
+
+	if test.Status != 0 && resolution.Status != test.Status {
+		return fmt.Sprintf("expected status %d, got %d", test.Status, resolution.Status)
+	}
+
+	return ""
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Status of a running server, as reported over the unix socket
+type Status struct {
+	Version    string    `yaml:"version" json:"version"`
+	Uptime     string    `yaml:"uptime" json:"uptime"`
+	Source     string    `yaml:"source" json:"source"`
+	ConfigURI  string    `yaml:"config-uri,omitempty" json:"config-uri,omitempty"`
+	LoadedAt   time.Time `yaml:"loaded-at" json:"loaded-at"`
+	RulesCount int       `yaml:"rules-count" json:"rules-count"`
+
+	// Set when the last config load failed, the server keeps serving the last valid config
+	LastLoadError   string     `yaml:"last-load-error,omitempty" json:"last-load-error,omitempty"`
+	LastLoadErrorAt *time.Time `yaml:"last-load-error-at,omitempty" json:"last-load-error-at,omitempty"`
+	FailedLoads     int        `yaml:"failed-loads" json:"failed-loads"`
+}
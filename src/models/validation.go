@@ -0,0 +1,844 @@
+package models
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/AmrSaber/redirector/src/lib/jwt"
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/passwords"
+	"github.com/AmrSaber/redirector/src/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Severities of diagnostics, only errors make the config invalid
+const (
+	SEVERITY_ERROR   = "error"
+	SEVERITY_WARNING = "warning"
+)
+
+// Problem found in the config, positioned in the YAML document when the position is known
+type Diagnostic struct {
+	Severity string `json:"severity"`
+
+	// Stable identifier of the kind of problem, e.g. invalid-url
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Path of the field in the config, e.g. redirects[2].to
+	Path string `json:"path,omitempty"`
+
+	// Index of the redirect the problem is in, if any
+	RuleIndex *int `json:"rule-index,omitempty"`
+
+	// 1-based position in the YAML document, 0 if unknown
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+
+	fieldPath []any
+}
+
+type Diagnostics []Diagnostic
+
+// Matches the line of YAML parsing errors, e.g. "yaml: line 3: did not find expected key"
+var yamlErrorLineRegex = regexp.MustCompile(`^line (\d+): `)
+
+// Path of a config field, made of map keys (strings) and list indexes (ints)
+func fieldPath(parts ...any) []any {
+	return parts
+}
+
+func NewDiagnostic(severity, code string, path []any, message string) Diagnostic {
+	diagnostic := Diagnostic{Severity: severity, Code: code, Message: message, fieldPath: path}
+
+	var builder strings.Builder
+	for _, part := range path {
+		if index, ok := part.(int); ok {
+			fmt.Fprintf(&builder, "[%d]", index)
+			continue
+		}
+
+		if builder.Len() > 0 {
+			builder.WriteByte('.')
+		}
+		fmt.Fprint(&builder, part)
+	}
+	diagnostic.Path = builder.String()
+
+	if len(path) >= 2 && path[0] == "redirects" {
+		if index, ok := path[1].(int); ok {
+			diagnostic.RuleIndex = &index
+		}
+	}
+
+	return diagnostic
+}
+
+// Adds an error diagnostic
+func (diagnostics *Diagnostics) add(code string, path []any, message string) {
+	*diagnostics = append(*diagnostics, NewDiagnostic(SEVERITY_ERROR, code, path, message))
+}
+
+func (diagnostics Diagnostics) HasErrors() bool {
+	return slices.ContainsFunc(diagnostics, func(d Diagnostic) bool { return d.Severity == SEVERITY_ERROR })
+}
+
+// Returns the messages of the error diagnostics, one per line
+func (diagnostics Diagnostics) ErrorMessages() string {
+	messages := []string{}
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == SEVERITY_ERROR {
+			messages = append(messages, diagnostic.Message)
+		}
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Sets the line and column of each diagnostic from its field, or from the closest parent that exists in the document
+func (diagnostics Diagnostics) resolvePositions(root *yaml.Node) {
+	for i := range diagnostics {
+		if diagnostics[i].Line != 0 {
+			continue
+		}
+
+		if node := findNode(root, diagnostics[i].fieldPath); node != nil {
+			diagnostics[i].Line = node.Line
+			diagnostics[i].Column = node.Column
+		}
+	}
+}
+
+// Returns the node at the given path, or its closest existing parent
+func findNode(root *yaml.Node, path []any) *yaml.Node {
+	node := root
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+
+		node = node.Content[0]
+	}
+
+	for _, part := range path {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+
+		var next *yaml.Node
+
+		switch part := part.(type) {
+		case int:
+			if node.Kind == yaml.SequenceNode && part < len(node.Content) {
+				next = node.Content[part]
+			}
+
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					if node.Content[i].Value == part {
+						next = node.Content[i+1]
+					}
+				}
+			}
+		}
+
+		if next == nil {
+			break
+		}
+
+		node = next
+	}
+
+	return node
+}
+
+// Check of a valid config, diagnostics of the checks are positioned like the validation ones
+type ConfigCheck func(config Config) Diagnostics
+
+// Parses and validates the YAML config, and returns all the problems found sorted by their position
+// Auth files are only loaded if the config itself is valid, as they depend on it, and so are the lint warnings and the given checks
+func Validate(yamlBody []byte, checks ...ConfigCheck) Diagnostics {
+	var root yaml.Node
+	if err := yaml.Unmarshal(yamlBody, &root); err != nil {
+		return Diagnostics{yamlErrorDiagnostic("invalid-yaml", err.Error())}
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(yamlBody, &config); err != nil {
+		if typeError, ok := err.(*yaml.TypeError); ok {
+			diagnostics := Diagnostics{}
+			for _, message := range typeError.Errors {
+				diagnostics = append(diagnostics, yamlErrorDiagnostic("invalid-type", message))
+			}
+
+			return diagnostics
+		}
+
+		return Diagnostics{yamlErrorDiagnostic("invalid-yaml", err.Error())}
+	}
+
+	diagnostics := config.validate()
+	if !diagnostics.HasErrors() {
+		diagnostics = append(diagnostics, config.loadAuthFiles()...)
+	}
+
+	// Warnings are only looked for in valid configs
+	if !diagnostics.HasErrors() {
+		diagnostics = append(diagnostics, config.lint()...)
+
+		for _, check := range checks {
+			diagnostics = append(diagnostics, check(config)...)
+		}
+	}
+
+	diagnostics.resolvePositions(&root)
+
+	slices.SortStableFunc(diagnostics, func(a, b Diagnostic) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+
+	return diagnostics
+}
+
+func yamlErrorDiagnostic(code, message string) Diagnostic {
+	message = strings.TrimPrefix(message, "yaml: ")
+	diagnostic := NewDiagnostic(SEVERITY_ERROR, code, nil, message)
+
+	if match := yamlErrorLineRegex.FindStringSubmatch(message); match != nil {
+		diagnostic.Line, _ = strconv.Atoi(match[1])
+		diagnostic.Message = strings.Replace(message, match[0], "", 1)
+	}
+
+	return diagnostic
+}
+
+// Validates the config, positions of the diagnostics are resolved later from the YAML document
+func (c Config) validate() Diagnostics {
+	diagnostics := Diagnostics{}
+
+	// Validate auth
+	if c.Auth != nil {
+		for key, auth := range c.Auth.BasicAuth {
+			// Validate that each object contains a username and a password
+			for i, userConfig := range auth.Users {
+				if userConfig.Username == "" {
+					diagnostics.add("missing-username", fieldPath("auth", "basic-auth", key, "users", i), fmt.Sprintf(`Auth "username" must be provided [@basic-auth %q #%d]`, key, i))
+				}
+
+				if userConfig.Password == "" && userConfig.PasswordFile == "" {
+					diagnostics.add("missing-password", fieldPath("auth", "basic-auth", key, "users", i), fmt.Sprintf(`Auth "password" or "password-file" must be provided [@basic-auth %q #%d]`, key, i))
+				}
+
+				if userConfig.Password != "" && userConfig.PasswordFile != "" {
+					diagnostics.add("conflicting-fields", fieldPath("auth", "basic-auth", key, "users", i, "password-file"), fmt.Sprintf(`Auth "password" and "password-file" cannot both be provided [@basic-auth %q #%d]`, key, i))
+				}
+
+				if err := passwords.ValidatePassword(userConfig.Password); err != nil {
+					diagnostics.add("invalid-password-hash", fieldPath("auth", "basic-auth", key, "users", i, "password"), fmt.Sprintf(`Invalid auth "password" [@basic-auth %q #%d]: %s`, key, i, err))
+				}
+			}
+		}
+
+		if protection := c.Auth.Protection; protection != nil {
+			if (protection.MaxIPFailures != nil && *protection.MaxIPFailures < 0) || (protection.MaxUserFailures != nil && *protection.MaxUserFailures < 0) {
+				diagnostics.add("negative-value", fieldPath("auth", "protection"), `Auth protection "max-ip-failures" and "max-user-failures" cannot be negative`)
+			}
+
+			if protection.Window < 0 || protection.Lockout < 0 || protection.Delay < 0 || protection.MaxDelay < 0 {
+				diagnostics.add("negative-value", fieldPath("auth", "protection"), `Auth protection durations cannot be negative`)
+			}
+
+			if protection.Delay != 0 && protection.MaxDelay != 0 && protection.MaxDelay < protection.Delay {
+				diagnostics.add("invalid-max-delay", fieldPath("auth", "protection", "max-delay"), fmt.Sprintf(`Auth protection "max-delay" (%s) cannot be less than "delay" (%s)`, protection.MaxDelay, protection.Delay))
+			}
+
+			for i, proxy := range protection.TrustedProxies {
+				if _, err := parseTrustedProxy(proxy); err != nil {
+					diagnostics.add("invalid-trusted-proxy", fieldPath("auth", "protection", "trusted-proxies", i), fmt.Sprintf(`Invalid auth protection trusted proxy %q, must be an IP address or CIDR range`, proxy))
+				}
+			}
+		}
+
+		for key, auth := range c.Auth.ForwardAuth {
+			if addressUrl, err := url.Parse(auth.Address); err != nil || (addressUrl.Scheme != "http" && addressUrl.Scheme != "https") || addressUrl.Host == "" {
+				diagnostics.add("invalid-url", fieldPath("auth", "forward-auth", key, "address"), fmt.Sprintf(`Invalid forward auth "address" [@forward-auth %q]: %s`, key, auth.Address))
+			}
+
+			if auth.Timeout < 0 {
+				diagnostics.add("negative-value", fieldPath("auth", "forward-auth", key, "timeout"), fmt.Sprintf(`Forward auth "timeout" cannot be negative [@forward-auth %q]`, key))
+			}
+		}
+
+		for key, auth := range c.Auth.OIDC {
+			if issuerUrl, err := url.Parse(auth.Issuer); err != nil || (issuerUrl.Scheme != "http" && issuerUrl.Scheme != "https") || issuerUrl.Host == "" {
+				diagnostics.add("invalid-url", fieldPath("auth", "oidc", key, "issuer"), fmt.Sprintf(`Invalid OIDC "issuer" [@oidc %q]: %s`, key, auth.Issuer))
+			}
+
+			if auth.ClientID == "" {
+				diagnostics.add("missing-field", fieldPath("auth", "oidc", key), fmt.Sprintf(`OIDC "client-id" must be provided [@oidc %q]`, key))
+			}
+
+			if auth.ClientSecret != "" && auth.ClientSecretFile != "" {
+				diagnostics.add("conflicting-fields", fieldPath("auth", "oidc", key, "client-secret-file"), fmt.Sprintf(`OIDC "client-secret" and "client-secret-file" cannot both be provided [@oidc %q]`, key))
+			}
+
+			if auth.CookieSecret != "" && auth.CookieSecretFile != "" {
+				diagnostics.add("conflicting-fields", fieldPath("auth", "oidc", key, "cookie-secret-file"), fmt.Sprintf(`OIDC "cookie-secret" and "cookie-secret-file" cannot both be provided [@oidc %q]`, key))
+			}
+
+			if auth.CallbackPath != "" && !strings.HasPrefix(auth.CallbackPath, "/") {
+				diagnostics.add("invalid-path", fieldPath("auth", "oidc", key, "callback-path"), fmt.Sprintf(`OIDC "callback-path" must start with "/" [@oidc %q]: %s`, key, auth.CallbackPath))
+			}
+
+			if auth.CookieName != "" && !utils.CookieNameRegex.MatchString(auth.CookieName) {
+				diagnostics.add("invalid-cookie-name", fieldPath("auth", "oidc", key, "cookie-name"), fmt.Sprintf(`Invalid OIDC "cookie-name" [@oidc %q]: %s`, key, auth.CookieName))
+			}
+
+			if auth.SessionDuration < 0 {
+				diagnostics.add("negative-value", fieldPath("auth", "oidc", key, "session-duration"), fmt.Sprintf(`OIDC "session-duration" cannot be negative [@oidc %q]`, key))
+			}
+
+			if len(auth.Scopes) > 0 && !slices.Contains(auth.Scopes, "openid") {
+				diagnostics.add("missing-openid-scope", fieldPath("auth", "oidc", key, "scopes"), fmt.Sprintf(`OIDC "scopes" must contain "openid" [@oidc %q]`, key))
+			}
+		}
+
+		for key, auth := range c.Auth.Jwt {
+			keySources := 0
+			for _, source := range []string{auth.Secret, auth.SecretFile, auth.PublicKeyFile, auth.JwksFile} {
+				if source != "" {
+					keySources++
+				}
+			}
+
+			if keySources != 1 {
+				diagnostics.add("invalid-jwt-key", fieldPath("auth", "jwt", key), fmt.Sprintf(`Exactly one of JWT "secret", "secret-file", "public-key-file" or "jwks-file" must be provided [@jwt %q]`, key))
+			}
+
+			for _, algorithm := range auth.Algorithms {
+				if !slices.Contains(jwt.Algorithms, algorithm) {
+					diagnostics.add("invalid-jwt-algorithm", fieldPath("auth", "jwt", key, "algorithms"), fmt.Sprintf(`Invalid JWT algorithm [@jwt %q]: %s`, key, algorithm))
+				}
+			}
+		}
+
+		for key, auth := range c.Auth.ApiKey {
+			if auth.Header != "" && !utils.HeaderNameRegex.MatchString(auth.Header) {
+				diagnostics.add("invalid-header", fieldPath("auth", "api-key", key, "header"), fmt.Sprintf(`Invalid API key "header" [@api-key %q]: %s`, key, auth.Header))
+			}
+
+			keyNames := make(map[string]any, len(auth.Keys))
+			for i, apiKey := range auth.Keys {
+				if apiKey.Name == "" {
+					diagnostics.add("missing-field", fieldPath("auth", "api-key", key, "keys", i), fmt.Sprintf(`API key "name" must be provided [@api-key %q #%d]`, key, i))
+				} else if _, ok := keyNames[apiKey.Name]; ok {
+					diagnostics.add("duplicate-name", fieldPath("auth", "api-key", key, "keys", i, "name"), fmt.Sprintf(`Found duplicate API key name %q [@api-key %q #%d]`, apiKey.Name, key, i))
+				}
+				keyNames[apiKey.Name] = struct{}{}
+
+				if (apiKey.Key == "") == (apiKey.KeyFile == "") {
+					diagnostics.add("invalid-api-key", fieldPath("auth", "api-key", key, "keys", i), fmt.Sprintf(`Exactly one of API key "key" or "key-file" must be provided [@api-key %q #%d]`, key, i))
+				}
+
+				if err := validateApiKey(apiKey.Key); err != nil {
+					diagnostics.add("invalid-password-hash", fieldPath("auth", "api-key", key, "keys", i, "key"), fmt.Sprintf(`Invalid API "key" [@api-key %q #%d]: %s`, key, i, err))
+				}
+			}
+		}
+
+		// Validate that auth names are unique across schema types
+		{
+			authTypes := make(map[string][]string)
+			addNames := func(authType string, names []string) {
+				for _, name := range names {
+					authTypes[name] = append(authTypes[name], authType)
+				}
+			}
+
+			addNames("basic-auth", utils.GetMapKeys(c.Auth.BasicAuth))
+			addNames("forward-auth", utils.GetMapKeys(c.Auth.ForwardAuth))
+			addNames("oidc", utils.GetMapKeys(c.Auth.OIDC))
+			addNames("jwt", utils.GetMapKeys(c.Auth.Jwt))
+			addNames("api-key", utils.GetMapKeys(c.Auth.ApiKey))
+
+			for name, types := range authTypes {
+				if len(types) > 1 {
+					diagnostics.add("duplicate-auth-name", fieldPath("auth", types[len(types)-1], name), fmt.Sprintf("Found duplicate auth name %q in %s", name, strings.Join(types, " and ")))
+				}
+			}
+		}
+
+		// Validate that there are no duplicate usernames within same schema
+		{
+			for authName, auth := range c.Auth.BasicAuth {
+				authUsers := make(map[string][]int, len(auth.Users))
+
+				for i, userConfig := range auth.Users {
+					username := userConfig.Username
+					authUsers[username] = append(authUsers[username], i)
+				}
+
+				for username, occurrences := range authUsers {
+					if len(occurrences) <= 1 {
+						continue
+					}
+
+					diagnostics.add(
+						"duplicate-username",
+						fieldPath("auth", "basic-auth", authName, "users", occurrences[1], "username"),
+						fmt.Sprintf(
+							"Found duplicate username %q in basic-auth %q at: %s",
+							username,
+							authName,
+							strings.Join(utils.ToStringSlice(occurrences), ", "),
+						),
+					)
+				}
+			}
+		}
+	}
+
+	for i, r := range c.Redirects {
+		// Trim trailing slash from each domain
+		r.From = strings.TrimSuffix(r.From, "/")
+		r.To = strings.TrimSuffix(r.To, "/")
+
+		if r.Mode != "" && r.Mode != MODE_REDIRECT && r.Mode != MODE_PROXY {
+			diagnostics.add("invalid-mode", fieldPath("redirects", i, "mode"), fmt.Sprintf(`Invalid "mode" [#%d]: %s`, i, r.Mode))
+		}
+
+		if r.Status != 0 {
+			if !slices.Contains(REDIRECT_STATUSES, r.Status) {
+				diagnostics.add("invalid-status", fieldPath("redirects", i, "status"), fmt.Sprintf(`Invalid "status" [#%d]: %d, must be one of (%s)`, i, r.Status, redirectStatusesList()))
+			}
+
+			if r.TempRedirect != nil && *r.TempRedirect != isTemporaryStatus(r.Status) {
+				diagnostics.add("conflicting-fields", fieldPath("redirects", i, "temp-redirect"), fmt.Sprintf(`"temp-redirect" conflicts with "status" %d [#%d]`, r.Status, i))
+			}
+
+			if r.Mode == MODE_PROXY {
+				diagnostics.add("conflicting-fields", fieldPath("redirects", i, "status"), fmt.Sprintf(`"status" cannot be provided in proxy mode [#%d]`, i))
+			}
+		}
+
+		if r.FromRegex != "" {
+			diagnostics = append(diagnostics, validateRegexRedirect(r, i)...)
+		} else {
+			diagnostics = append(diagnostics, validateDomainRedirect(r, i)...)
+		}
+
+		if len(r.AuthNames) > 0 {
+			realms := make(map[string]any)
+			forwardAuthsCount := 0
+			oidcAuthsCount := 0
+			availableAuths := c.GetAvailableAuthNames()
+			for _, authName := range r.AuthNames {
+				if !slices.Contains(availableAuths, authName) {
+					diagnostics.add("auth-not-found", fieldPath("redirects", i, "auth"), fmt.Sprintf("Auth %q not found [@redirect#%d]", authName, i))
+					continue
+				}
+
+				if _, ok := c.Auth.ForwardAuth[authName]; ok {
+					forwardAuthsCount++
+					continue
+				}
+
+				if _, ok := c.Auth.OIDC[authName]; ok {
+					oidcAuthsCount++
+					continue
+				}
+
+				basicAuth, ok := c.Auth.BasicAuth[authName]
+				if !ok {
+					continue
+				}
+
+				realm := basicAuth.Realm
+				if realm == "" {
+					realm = utils.DEFAULT_REALM
+				}
+
+				realms[realm] = struct{}{}
+			}
+
+			// The forward auth endpoint makes the decision, so it cannot be combined with other auths
+			if forwardAuthsCount > 0 && len(r.AuthNames) > 1 {
+				diagnostics.add("invalid-auth-combination", fieldPath("redirects", i, "auth"), fmt.Sprintf("Forward auth cannot be combined with other auths [@redirect#%d]", i))
+			}
+
+			// Users are sent to a single provider to log in, so OIDC cannot be combined with other auths
+			if oidcAuthsCount > 0 && len(r.AuthNames) > 1 {
+				diagnostics.add("invalid-auth-combination", fieldPath("redirects", i, "auth"), fmt.Sprintf("OIDC auth cannot be combined with other auths [@redirect#%d]", i))
+			}
+
+			// Validate that there are no mixed realms
+			if len(realms) > 1 {
+				foundRealms := utils.MapSlice(utils.GetMapKeys(realms), func(str string) string { return fmt.Sprintf("%q", str) })
+				diagnostics.add(
+					"mixed-realms",
+					fieldPath("redirects", i, "auth"),
+					fmt.Sprintf(
+						"Found mixed realms (%s) at redirect #%d. All linked auths must have the same realm",
+						strings.Join(foundRealms, ", "), i,
+					),
+				)
+			}
+		}
+	}
+
+	if c.Tls != nil {
+		if len(c.Tls.Certificates) == 0 && c.Tls.CertificatesDir == "" && c.Tls.Acme == nil {
+			diagnostics.add("missing-field", fieldPath("tls"), `TLS must have "certificates", "certificates-dir" or "acme"`)
+		}
+
+		if c.Tls.Acme != nil {
+			if c.Tls.Acme.CacheDir == "" {
+				diagnostics.add("missing-field", fieldPath("tls", "acme"), `ACME "cache-dir" must be provided`)
+			}
+
+			if directoryUrl, err := url.Parse(c.Tls.Acme.DirectoryURL); c.Tls.Acme.DirectoryURL != "" && (err != nil || directoryUrl.Host == "") {
+				diagnostics.add("invalid-url", fieldPath("tls", "acme", "directory-url"), fmt.Sprintf(`Invalid ACME "directory-url": %s`, c.Tls.Acme.DirectoryURL))
+			}
+		}
+
+		httpPort := c.Port
+		if httpPort == 0 {
+			httpPort = 80
+		}
+
+		if c.Tls.Port == httpPort {
+			diagnostics.add("port-conflict", fieldPath("tls", "port"), fmt.Sprintf(`TLS "port" cannot be the same as "port": %d`, httpPort))
+		}
+
+		for i, cert := range c.Tls.Certificates {
+			if cert.Cert == "" || cert.Key == "" {
+				diagnostics.add("missing-field", fieldPath("tls", "certificates", i), fmt.Sprintf(`TLS "cert" and "key" must be provided [@certificates#%d]`, i))
+			}
+
+			for _, domain := range cert.Domains {
+				if !utils.DomainRegex.MatchString(domain) {
+					diagnostics.add("invalid-domain", fieldPath("tls", "certificates", i, "domains"), fmt.Sprintf(`Invalid TLS domain [@certificates#%d]: %s`, i, domain))
+				}
+			}
+		}
+	}
+
+	if c.Metrics != nil {
+		httpPort := c.Port
+		if httpPort == 0 {
+			httpPort = 80
+		}
+
+		if c.Metrics.Port == 0 {
+			diagnostics.add("missing-field", fieldPath("metrics"), `Metrics "port" must be provided`)
+		} else if c.Metrics.Port == httpPort || (c.Tls != nil && c.Metrics.Port == c.Tls.Port) {
+			diagnostics.add("port-conflict", fieldPath("metrics", "port"), fmt.Sprintf(`Metrics "port" cannot be the same as other ports: %d`, c.Metrics.Port))
+		}
+
+		if c.Metrics.Path != "" && !strings.HasPrefix(c.Metrics.Path, "/") {
+			diagnostics.add("invalid-path", fieldPath("metrics", "path"), fmt.Sprintf(`Metrics "path" must start with "/": %s`, c.Metrics.Path))
+		}
+	}
+
+	if c.Status != 0 {
+		if !slices.Contains(REDIRECT_STATUSES, c.Status) {
+			diagnostics.add("invalid-status", fieldPath("status"), fmt.Sprintf(`Invalid "status": %d, must be one of (%s)`, c.Status, redirectStatusesList()))
+		}
+
+		if c.TempRedirect != nil && *c.TempRedirect != isTemporaryStatus(c.Status) {
+			diagnostics.add("conflicting-fields", fieldPath("temp-redirect"), fmt.Sprintf(`"temp-redirect" conflicts with "status" %d`, c.Status))
+		}
+	}
+
+	if c.LogLevel != "" {
+		if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+			diagnostics.add("invalid-log-level", fieldPath("log-level"), fmt.Sprintf(`Invalid "log-level": %s`, c.LogLevel))
+		}
+	}
+
+	if c.AccessLog != nil {
+		formats := []string{logger.ACCESS_LOG_FORMAT_JSON, logger.ACCESS_LOG_FORMAT_COMBINED, logger.ACCESS_LOG_FORMAT_TEMPLATE}
+		if c.AccessLog.Format != "" && !slices.Contains(formats, c.AccessLog.Format) {
+			diagnostics.add("invalid-access-log-format", fieldPath("access-log", "format"), fmt.Sprintf(`Invalid access log "format": %s`, c.AccessLog.Format))
+		}
+
+		if c.AccessLog.Format == logger.ACCESS_LOG_FORMAT_TEMPLATE {
+			if c.AccessLog.Template == "" {
+				diagnostics.add("missing-field", fieldPath("access-log"), `Access log "template" must be provided with template format`)
+			} else if _, err := logger.ParseAccessLogTemplate(c.AccessLog.Template); err != nil {
+				diagnostics.add("invalid-access-log-template", fieldPath("access-log", "template"), fmt.Sprintf(`Invalid access log "template": %s`, err))
+			}
+		}
+	}
+
+	if c.UrlConfigRefresh != nil {
+		for i, d := range c.UrlConfigRefresh.RefreshDomains {
+			if !utils.DomainRegex.MatchString(d.Domain) {
+				diagnostics.add("invalid-domain", fieldPath("url-config-refresh", "refresh-domains", i, "domain"), fmt.Sprintf(`Invalid "domain" for refresh domains [#%d]: %s`, i, d.Domain))
+			}
+
+			if d.RefreshOn != REFRESH_ON_HIT && d.RefreshOn != REFRESH_ON_MISS {
+				diagnostics.add("invalid-refresh-on", fieldPath("url-config-refresh", "refresh-domains", i, "refresh-on"), fmt.Sprintf(`Invalid "refresh-on" for refresh domains [#%d]: %s`, i, d.RefreshOn))
+			}
+		}
+	}
+
+	for i, test := range c.Tests {
+		if testUrl, err := url.Parse(test.URL); err != nil || (testUrl.Scheme != "http" && testUrl.Scheme != "https") || testUrl.Host == "" {
+			diagnostics.add("invalid-url", fieldPath("tests", i, "url"), fmt.Sprintf(`Invalid test "url" [@test#%d]: %s`, i, test.URL))
+		}
+
+		if test.Target == "" && test.Status == 0 {
+			diagnostics.add("missing-field", fieldPath("tests", i), fmt.Sprintf(`Test "target" or "status" must be provided [@test#%d]`, i))
+		}
+
+		if test.Status != 0 && (test.Status < 100 || test.Status > 599) {
+			diagnostics.add("invalid-status", fieldPath("tests", i, "status"), fmt.Sprintf(`Invalid test "status" [@test#%d]: %d`, i, test.Status))
+		}
+	}
+
+	return diagnostics
+}
+
+// Validates a redirect that matches on "from" domain
+func validateDomainRedirect(r Redirect, i int) Diagnostics {
+	diagnostics := Diagnostics{}
+
+	// Validate that each "from" is a valid domain name and each "to" is a valid URL
+	if !utils.DomainRegex.MatchString(r.From) {
+		diagnostics.add("invalid-domain", fieldPath("redirects", i, "from"), fmt.Sprintf(`Invalid "from" domain [#%d]: %s`, i, r.From))
+	}
+
+	if !utils.UrlRegex.MatchString(r.To) {
+		diagnostics.add("invalid-url", fieldPath("redirects", i, "to"), fmt.Sprintf(`Invalid "to" URL [#%d]: %s`, i, r.To))
+	}
+
+	// Prefix path rules append the remainder of the path to "to", so it can have a path of its own
+	isPrefixPath := r.Path != "" && (r.PathMatch == "" || r.PathMatch == PATH_MATCH_PREFIX)
+	preservesPath := r.PreservePath || r.Mode == MODE_PROXY
+	if preservesPath && !isPrefixPath && utils.HasPathRegex.MatchString(r.To) {
+		diagnostics.add("invalid-to-path", fieldPath("redirects", i, "to"), fmt.Sprintf(`"To" URL cannot contain path and set preserve path or proxy mode [#%d]: %s`, i, r.To))
+	}
+
+	if r.Path != "" && !strings.HasPrefix(r.Path, "/") {
+		diagnostics.add("invalid-path", fieldPath("redirects", i, "path"), fmt.Sprintf(`"path" must start with "/" [#%d]: %s`, i, r.Path))
+	}
+
+	if r.PathMatch != "" {
+		if r.Path == "" {
+			diagnostics.add("missing-field", fieldPath("redirects", i, "path-match"), fmt.Sprintf(`"path-match" is set without "path" [#%d]`, i))
+		}
+
+		if !slices.Contains([]string{PATH_MATCH_EXACT, PATH_MATCH_PREFIX, PATH_MATCH_GLOB}, r.PathMatch) {
+			diagnostics.add("invalid-path-match", fieldPath("redirects", i, "path-match"), fmt.Sprintf(`Invalid "path-match" [#%d]: %s`, i, r.PathMatch))
+		}
+	}
+
+	if r.PathMatch == PATH_MATCH_GLOB {
+		if _, err := path.Match(r.Path, ""); err != nil {
+			diagnostics.add("invalid-path", fieldPath("redirects", i, "path"), fmt.Sprintf(`Invalid glob "path" [#%d]: %s`, i, r.Path))
+		}
+	}
+
+	fromSections := strings.Split(r.From, ".")
+	if multiCount := utils.CountSlice(fromSections, "**"); multiCount > 1 {
+		diagnostics.add("invalid-domain", fieldPath("redirects", i, "from"), fmt.Sprintf(`"from" can contain at most one "**" (found %d) [#%d]: %s`, multiCount, i, r.From))
+	}
+
+	if toWildcardsCount := strings.Count(r.To, "*"); toWildcardsCount > 0 {
+		toUrl, _ := url.Parse(r.To)
+		toSections := strings.Split(toUrl.Host, ".")
+
+		toSectionsCount := len(toSections)
+		fromSectionsCount := len(fromSections)
+
+		if toSectionsCount != fromSectionsCount {
+			diagnostics.add(
+				"wildcard-mismatch",
+				fieldPath("redirects", i, "to"),
+				fmt.Sprintf(
+					`"to" has wildcard(s) but "To" sections (found %d) and "From" sections (found %d) don't match `,
+					toSectionsCount,
+					fromSectionsCount,
+				),
+			)
+		} else if slices.Index(toSections, "**") != slices.Index(fromSections, "**") {
+			diagnostics.add("wildcard-mismatch", fieldPath("redirects", i, "to"), fmt.Sprintf(`"to" and "from" must both have "**" at the same section [#%d]`, i))
+		}
+	}
+
+	return diagnostics
+}
+
+// Validates a redirect that matches on "from-regex"
+func validateRegexRedirect(r Redirect, i int) Diagnostics {
+	diagnostics := Diagnostics{}
+
+	if r.From != "" || r.Path != "" || r.PathMatch != "" {
+		diagnostics.add("conflicting-fields", fieldPath("redirects", i, "from-regex"), fmt.Sprintf(`"from-regex" cannot be combined with "from", "path" or "path-match" [#%d]`, i))
+	}
+
+	if r.PreservePath {
+		diagnostics.add("conflicting-fields", fieldPath("redirects", i, "preserve-path"), fmt.Sprintf(`"from-regex" cannot be combined with "preserve-path", use captures in "to" instead [#%d]`, i))
+	}
+
+	fromRegex, err := compileFromRegex(r.FromRegex)
+	if err != nil {
+		diagnostics.add("invalid-regex", fieldPath("redirects", i, "from-regex"), fmt.Sprintf(`Invalid "from-regex" [#%d]: %s`, i, err))
+		return diagnostics
+	}
+
+	// Validate that all references in "to" are defined in the regex
+	for _, reference := range utils.RegexReferenceRegex.FindAllStringSubmatch(r.To, -1) {
+		name := reference[1] + reference[2]
+
+		if index, err := strconv.Atoi(name); err == nil {
+			if index > fromRegex.NumSubexp() {
+				diagnostics.add("undefined-capture-group", fieldPath("redirects", i, "to"), fmt.Sprintf(`"to" references undefined capture group %q [#%d]`, reference[0], i))
+			}
+		} else if fromRegex.SubexpIndex(name) == -1 {
+			diagnostics.add("undefined-capture-group", fieldPath("redirects", i, "to"), fmt.Sprintf(`"to" references undefined capture group %q [#%d]`, reference[0], i))
+		}
+	}
+
+	// References are only known after matching, so substitute them to validate the rest of the URL
+	substitutedTo := utils.RegexReferenceRegex.ReplaceAllString(r.To, "x")
+	if !utils.UrlRegex.MatchString(substitutedTo) || strings.Contains(r.To, "*") {
+		diagnostics.add("invalid-url", fieldPath("redirects", i, "to"), fmt.Sprintf(`Invalid "to" URL [#%d]: %s`, i, r.To))
+	}
+
+	return diagnostics
+}
+
+// Reads the password files and htpasswd files of basic auth schemas, the secret files of OIDC schemas,
+// the keys of JWT schemas and the key files of API key schemas
+func (c Config) loadAuthFiles() Diagnostics {
+	diagnostics := Diagnostics{}
+	if c.Auth == nil {
+		return diagnostics
+	}
+
+	for key, auth := range c.Auth.BasicAuth {
+		for i, userConfig := range auth.Users {
+			if userConfig.PasswordFile == "" {
+				continue
+			}
+
+			content, err := os.ReadFile(userConfig.PasswordFile)
+			if err != nil {
+				diagnostics.add("unreadable-file", fieldPath("auth", "basic-auth", key, "users", i, "password-file"), fmt.Sprintf(`Could not read "password-file" [@basic-auth %q #%d]: %s`, key, i, err))
+				continue
+			}
+
+			password := strings.TrimRight(string(content), "\r\n")
+			if password == "" {
+				diagnostics.add("empty-file", fieldPath("auth", "basic-auth", key, "users", i, "password-file"), fmt.Sprintf(`Empty "password-file" [@basic-auth %q #%d]: %s`, key, i, userConfig.PasswordFile))
+			} else if err := passwords.ValidatePassword(password); err != nil {
+				diagnostics.add("invalid-password-hash", fieldPath("auth", "basic-auth", key, "users", i, "password-file"), fmt.Sprintf(`Invalid password in "password-file" [@basic-auth %q #%d]: %s`, key, i, err))
+			}
+
+			auth.Users[i].filePassword = password
+		}
+
+		if auth.HtpasswdFile == "" {
+			continue
+		}
+
+		entries, err := passwords.ReadHtpasswdFile(auth.HtpasswdFile)
+		if err != nil {
+			diagnostics.add("unreadable-file", fieldPath("auth", "basic-auth", key, "htpasswd-file"), fmt.Sprintf(`Could not read "htpasswd-file" [@basic-auth %q]: %s`, key, err))
+			continue
+		}
+
+		auth.htpasswdUsers = make([]BasicAuthUser, 0, len(entries))
+		for _, entry := range entries {
+			if auth.FindMatchingUser(entry.Username) != nil {
+				diagnostics.add("duplicate-username", fieldPath("auth", "basic-auth", key, "htpasswd-file"), fmt.Sprintf(`Found duplicate username %q in basic-auth %q and its "htpasswd-file"`, entry.Username, key))
+				continue
+			}
+
+			auth.htpasswdUsers = append(auth.htpasswdUsers, BasicAuthUser{Username: entry.Username, Password: entry.Hash})
+		}
+	}
+
+	for key, auth := range c.Auth.OIDC {
+		readSecret := func(field, secretFile string) string {
+			if secretFile == "" {
+				return ""
+			}
+
+			content, err := os.ReadFile(secretFile)
+			if err != nil {
+				diagnostics.add("unreadable-file", fieldPath("auth", "oidc", key, field), fmt.Sprintf(`Could not read %q [@oidc %q]: %s`, field, key, err))
+				return ""
+			}
+
+			secret := strings.TrimRight(string(content), "\r\n")
+			if secret == "" {
+				diagnostics.add("empty-file", fieldPath("auth", "oidc", key, field), fmt.Sprintf(`Empty %q [@oidc %q]: %s`, field, key, secretFile))
+			}
+
+			return secret
+		}
+
+		auth.fileClientSecret = readSecret("client-secret-file", auth.ClientSecretFile)
+		auth.fileCookieSecret = readSecret("cookie-secret-file", auth.CookieSecretFile)
+	}
+
+	for key, auth := range c.Auth.Jwt {
+		keys, err := auth.loadKeys()
+		if err != nil {
+			diagnostics.add("unreadable-file", fieldPath("auth", "jwt", key), fmt.Sprintf(`Could not load JWT key [@jwt %q]: %s`, key, err))
+			continue
+		}
+
+		auth.keys = keys
+	}
+
+	for key, auth := range c.Auth.ApiKey {
+		for i, apiKey := range auth.Keys {
+			if apiKey.KeyFile == "" {
+				continue
+			}
+
+			content, err := os.ReadFile(apiKey.KeyFile)
+			if err != nil {
+				diagnostics.add("unreadable-file", fieldPath("auth", "api-key", key, "keys", i, "key-file"), fmt.Sprintf(`Could not read "key-file" [@api-key %q #%d]: %s`, key, i, err))
+				continue
+			}
+
+			fileKey := strings.TrimRight(string(content), "\r\n")
+			if fileKey == "" {
+				diagnostics.add("empty-file", fieldPath("auth", "api-key", key, "keys", i, "key-file"), fmt.Sprintf(`Empty "key-file" [@api-key %q #%d]: %s`, key, i, apiKey.KeyFile))
+			} else if err := validateApiKey(fileKey); err != nil {
+				diagnostics.add("invalid-password-hash", fieldPath("auth", "api-key", key, "keys", i, "key-file"), fmt.Sprintf(`Invalid key in "key-file" [@api-key %q #%d]: %s`, key, i, err))
+			}
+
+			auth.Keys[i].fileKey = fileKey
+		}
+	}
+
+	return diagnostics
+}
+
+// Validates the plaintext key or hash of an API key
+// Requests are checked against every key of the schema, so slow hashes are rejected, as they would make each request cost a slow hash per key
+func validateApiKey(apiKey string) error {
+	if err := passwords.Validate(apiKey); err != nil {
+		return err
+	}
+
+	if algorithm := passwords.GetAlgorithm(apiKey); algorithm != "" && algorithm != passwords.ALGORITHM_SHA256 {
+		return fmt.Errorf("%s hashes are too slow for API keys, use a sha256 hash or a plaintext key", algorithm)
+	}
+
+	return nil
+}
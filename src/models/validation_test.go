@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	// Test valid config
+	diagnostics := Validate([]byte(`
+redirects:
+  - from: example.com
+    to: https://target.com
+`))
+
+	if len(diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %+v", diagnostics)
+	}
+
+	// Test diagnostics are positioned and sorted
+	diagnostics = Validate([]byte(`
+redirects:
+  - from: example.com
+    to: https://target.com
+  - from: bad
+    to: target.com
+    auth: [missing]
+`))
+
+	expected := []struct {
+		code, path   string
+		line, column int
+	}{
+		{"invalid-domain", "redirects[1].from", 5, 11},
+		{"invalid-url", "redirects[1].to", 6, 9},
+		{"auth-not-found", "redirects[1].auth", 7, 11},
+	}
+
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %+v", len(expected), diagnostics)
+	}
+
+	for i, diagnostic := range diagnostics {
+		if diagnostic.Code != expected[i].code || diagnostic.Path != expected[i].path || diagnostic.Line != expected[i].line || diagnostic.Column != expected[i].column {
+			t.Errorf("unexpected diagnostic #%d: %+v", i, diagnostic)
+		}
+
+		if diagnostic.Severity != SEVERITY_ERROR || diagnostic.RuleIndex == nil || *diagnostic.RuleIndex != 1 {
+			t.Errorf("unexpected severity or rule index of diagnostic #%d: %+v", i, diagnostic)
+		}
+	}
+
+	// Test missing fields are positioned at their closest parent
+	diagnostics = Validate([]byte(`
+redirects:
+  - from: example.com
+`))
+
+	if len(diagnostics) == 0 || diagnostics[0].Line != 3 || diagnostics[0].RuleIndex == nil {
+		t.Errorf("expected diagnostic at the redirect, got %+v", diagnostics)
+	}
+
+	// Test YAML and type errors
+	yamlErrors := map[string]string{
+		"port: [\n":          "invalid-yaml",
+		"port: abc\n":        "invalid-type",
+		"redirects: {}\n":    "invalid-type",
+		"\nlog-level: foo\n": "invalid-log-level",
+		"tests:\n  - url: example.com\n    status: 200\n": "invalid-url",
+		"tests:\n  - url: https://example.com\n":          "missing-field",
+	}
+
+	for yamlConfig, code := range yamlErrors {
+		diagnostics := Validate([]byte(yamlConfig))
+		if !diagnostics.HasErrors() || diagnostics[0].Code != code || diagnostics[0].Line == 0 {
+			t.Errorf("%q: expected %s error with position, got %+v", yamlConfig, code, diagnostics)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package servers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/lib/bruteforce"
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/models"
+	"github.com/AmrSaber/redirector/src/utils"
+)
+
+// Failed auth attempts are tracked across HTTP and HTTPS servers, and across config reloads
+var authGuard = bruteforce.NewGuard()
+
+// Checks the auth of the request, and responds with 401 if it's not authorized, or 429 if the client is locked out
+// Forward auth responses are decided by its endpoint, and OIDC users without a session are sent to log in
+// Returns whether the request should proceed
+func authorizeRequest(res http.ResponseWriter, req *http.Request, redirect *models.Redirect, protection *models.AuthProtectionOptions) bool {
+	if forwardAuth := redirect.GetForwardAuth(); forwardAuth != nil {
+		return authorizeForwardAuth(res, req, redirect, forwardAuth)
+	}
+
+	if oidcAuth := redirect.GetOIDC(); oidcAuth != nil {
+		return authorizeOIDC(res, req, redirect, oidcAuth)
+	}
+
+	requestPath := path.Join(req.Host, req.URL.Path)
+	isProtected := protection != nil && len(redirect.AuthNames) > 0
+
+	clientIP := utils.StripPort(req.RemoteAddr)
+	if protection != nil {
+		clientIP = protection.GetClientIP(req)
+	}
+
+	username, _, _ := req.BasicAuth()
+	hasCredentials := redirect.HasCredentials(req)
+
+	if isProtected {
+		if lockout := authGuard.GetLockout(clientIP, username); lockout > 0 {
+			respondLockedOut(res, lockout)
+
+			logRequest("Rejected request from locked out client for host: %s", requestPath)
+			lockedOutMetric.Inc(redirect.GetName())
+			setRequestInfo(req, redirect, "")
+			return false
+		}
+	}
+
+	if redirect.IsAuthorized(req) {
+		if isProtected && hasCredentials && username != "" {
+			authGuard.RecordSuccess(username)
+		}
+
+		redirect.RemoveCredentials(req)
+		return true
+	}
+
+	unauthorizedMetric.Inc(redirect.GetName())
+	setRequestInfo(req, redirect, "")
+
+	// Requests without credentials are only prompting for them, so they are not counted as failed attempts
+	redirect.RemoveCredentials(req)
+	if isProtected && hasCredentials {
+		failure := authGuard.RecordFailure(getGuardOptions(protection), clientIP, username)
+
+		if failure.LockedFor > 0 {
+			logger.Warnf(
+				"Locking out %s for %s after repeated failed auth attempts (client IP %s, username %q)",
+				strings.Join(failure.LockedKinds, " and "),
+				failure.LockedFor,
+				clientIP,
+				username,
+			)
+
+			for _, kind := range failure.LockedKinds {
+				authLockoutsMetric.Inc(kind)
+			}
+
+			respondLockedOut(res, failure.LockedFor)
+			return false
+		}
+
+		select {
+		case <-time.After(failure.Delay):
+		case <-req.Context().Done():
+		}
+	}
+
+	// Prompt for basic auth or bearer token
+	if len(redirect.ActualAuths.BasicAuth) > 0 {
+		res.Header().Add("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s", charset="UTF-8"`, redirect.GetBasicAuthRealm()))
+	}
+
+	if len(redirect.ActualAuths.Jwt) > 0 {
+		res.Header().Add("WWW-Authenticate", "Bearer")
+	}
+
+	http.Error(res, "Unauthorized", http.StatusUnauthorized)
+
+	logRequest("Received unauthorized request for host: %s", requestPath)
+	return false
+}
+
+func respondLockedOut(res http.ResponseWriter, lockout time.Duration) {
+	res.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockout.Seconds()))))
+	http.Error(res, "Too Many Requests", http.StatusTooManyRequests)
+}
+
+func getGuardOptions(protection *models.AuthProtectionOptions) bruteforce.Options {
+	return bruteforce.Options{
+		MaxIPFailures:   *protection.MaxIPFailures,
+		MaxUserFailures: *protection.MaxUserFailures,
+		Window:          protection.Window,
+		Lockout:         protection.Lockout,
+		Delay:           protection.Delay,
+		MaxDelay:        protection.MaxDelay,
+	}
+}
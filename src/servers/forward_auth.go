@@ -0,0 +1,122 @@
+package servers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/models"
+	"github.com/AmrSaber/redirector/src/utils"
+)
+
+// Redirects of the auth endpoint are not followed, so that login redirects are returned to the client
+var forwardAuthClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// Headers that only apply to a single connection, so they are not passed between the client and the auth endpoint
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+	"Content-Length",
+}
+
+// Calls the forward auth endpoint with the headers of the request, and returns whether the request is allowed
+// If allowed, the configured response headers are copied to the upstream request in proxy mode, or to the response otherwise
+// If denied, the endpoint response (e.g. a redirect to a login page) is returned to the client as is
+func authorizeForwardAuth(res http.ResponseWriter, req *http.Request, redirect *models.Redirect, auth *models.ForwardAuthSchema) bool {
+	requestPath := path.Join(req.Host, req.URL.Path)
+
+	ctx, cancel := context.WithTimeout(req.Context(), auth.Timeout)
+	defer cancel()
+
+	authRes, err := callForwardAuth(ctx, req, auth)
+	if err != nil {
+		logger.Errorf("Forward auth request to %s failed: %s", auth.Address, err)
+		http.Error(res, "Bad Gateway", http.StatusBadGateway)
+		setRequestInfo(req, redirect, "")
+		return false
+	}
+	defer authRes.Body.Close()
+
+	if authRes.StatusCode >= 200 && authRes.StatusCode < 300 {
+		target := res.Header()
+		if redirect.Mode == models.MODE_PROXY {
+			target = req.Header
+		}
+
+		// Values sent by the client are dropped even if the endpoint does not set them, so they cannot be spoofed
+		for _, name := range auth.ResponseHeaders {
+			target.Del(name)
+
+			for _, value := range authRes.Header.Values(name) {
+				target.Add(name, value)
+			}
+		}
+
+		return true
+	}
+
+	unauthorizedMetric.Inc(redirect.GetName())
+	setRequestInfo(req, redirect, "")
+
+	for name, values := range authRes.Header {
+		if !slices.Contains(hopByHopHeaders, http.CanonicalHeaderKey(name)) {
+			res.Header()[name] = values
+		}
+	}
+
+	res.WriteHeader(authRes.StatusCode)
+	_, _ = io.Copy(res, authRes.Body)
+
+	logRequest("Received unauthorized request for host: %s", requestPath)
+	return false
+}
+
+func callForwardAuth(ctx context.Context, req *http.Request, auth *models.ForwardAuthSchema) (*http.Response, error) {
+	authReq, err := http.NewRequestWithContext(ctx, http.MethodGet, auth.Address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range req.Header {
+		name = http.CanonicalHeaderKey(name)
+		if slices.Contains(hopByHopHeaders, name) {
+			continue
+		}
+
+		if len(auth.RequestHeaders) > 0 && !slices.ContainsFunc(auth.RequestHeaders, func(h string) bool { return http.CanonicalHeaderKey(h) == name }) {
+			continue
+		}
+
+		authReq.Header[name] = values
+	}
+
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+
+	// Details of the original request, as the endpoint only receives its headers
+	authReq.Header.Set("X-Forwarded-Method", req.Method)
+	authReq.Header.Set("X-Forwarded-Proto", proto)
+	authReq.Header.Set("X-Forwarded-Host", req.Host)
+	authReq.Header.Set("X-Forwarded-Uri", req.URL.RequestURI())
+
+	clientIP := utils.StripPort(req.RemoteAddr)
+	if forwardedFor := req.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		clientIP = forwardedFor + ", " + clientIP
+	}
+	authReq.Header.Set("X-Forwarded-For", clientIP)
+
+	return forwardAuthClient.Do(authReq)
+}
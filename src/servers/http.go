@@ -8,6 +8,7 @@ import (
 
 	"github.com/AmrSaber/redirector/src/config"
 	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/models"
 )
 
 func StartHttpServer(ctx context.Context, configManager *config.ConfigManager) <-chan error {
@@ -25,12 +26,12 @@ func StartHttpServer(ctx context.Context, configManager *config.ConfigManager) <
 		go func() {
 			<-ctx.Done()
 
-			logger.Std.Println("Stopping HTTP server...")
+			logger.Infof("Stopping HTTP server...")
 			_ = server.Shutdown(context.Background())
-			logger.Std.Println("HTTP server stopped")
+			logger.Infof("HTTP server stopped")
 		}()
 
-		logger.Std.Printf("Server listening on http://localhost:%d\n", configManager.GetPort())
+		logger.Infof("Server listening on http://localhost:%d", configManager.GetPort())
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			doneChan <- fmt.Errorf("could not start http server: %w", err)
 		}
@@ -42,13 +43,20 @@ func StartHttpServer(ctx context.Context, configManager *config.ConfigManager) <
 func getRedirectionMux(configs *config.ConfigManager) http.Handler {
 	handler := http.NewServeMux()
 
-	handler.HandleFunc("/", func(res http.ResponseWriter, req *http.Request) {
-		redirectInfo := configs.GetRedirect(req.Host)
+	redirectHandler := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		// Provider redirects users back to the callback path of the domain they requested
+		if auth := configs.MatchOIDCCallback(req.Host, req.URL.Path); auth != nil {
+			handleOIDCCallback(res, req, auth)
+			return
+		}
+
+		redirectInfo := configs.GetRedirect(req.Host, req.URL.Path)
 
 		requestPath := path.Join(req.Host, req.URL.Path)
 
 		if redirectInfo == nil {
-			logger.Std.Printf("Received request for unknown host: %s", requestPath)
+			logRequest("Received request for unknown host: %s", requestPath)
+			missesMetric.Inc()
 
 			// No redirects found, report 404
 			res.WriteHeader(http.StatusNotFound)
@@ -57,26 +65,35 @@ func getRedirectionMux(configs *config.ConfigManager) http.Handler {
 			return
 		}
 
-		// If user is not authorized, prompt for basic auth and return UNAUTHORIZED
-		if !redirectInfo.IsAuthorized(req) {
-			res.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s", charset="UTF-8"`, redirectInfo.GetBasicAuthRealm()))
-			http.Error(res, "Unauthorized", http.StatusUnauthorized)
-
-			logger.Std.Printf("Received unauthorized request for host: %s", requestPath)
+		if !authorizeRequest(res, req, redirectInfo, configs.GetAuthProtection()) {
 			return
 		}
 
-		redirectPath := redirectInfo.ResolvePath(req)
+		hitsMetric.Inc(redirectInfo.GetName())
 
-		logger.Std.Printf("Redirecting %q to %q", requestPath, redirectPath)
+		redirectPath := redirectInfo.ResolvePath(req)
+		setRequestInfo(req, redirectInfo, redirectPath)
 
-		status := http.StatusPermanentRedirect
-		if *redirectInfo.TempRedirect {
-			status = http.StatusTemporaryRedirect
+		if redirectInfo.Mode == models.MODE_PROXY {
+			logRequest("Proxying %q to %q", requestPath, redirectPath)
+			proxyRequest(res, req, redirectPath, len(redirectInfo.AuthNames) > 0)
+			return
 		}
 
-		http.Redirect(res, req, redirectPath, status)
+		logRequest("Redirecting %q to %q", requestPath, redirectPath)
+
+		http.Redirect(res, req, redirectPath, redirectInfo.GetStatusCode())
 	})
 
-	return handler
+	handler.Handle("/", redirectHandler)
+	handler.Handle("/.well-known/acme-challenge/", configs.HandleAcmeChallenge(redirectHandler))
+
+	return instrumentHandler(handler)
+}
+
+// Logs a line for the request, unless it's already logged by the access log
+func logRequest(format string, args ...any) {
+	if !logger.IsAccessLogEnabled() {
+		logger.Infof(format, args...)
+	}
 }
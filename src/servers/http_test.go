@@ -0,0 +1,506 @@
+package servers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/config"
+	"github.com/AmrSaber/redirector/src/lib/jwt"
+	"github.com/AmrSaber/redirector/src/lib/jwt/jwttest"
+)
+
+func createTestConfigManager(t *testing.T, yamlConfig string) *config.ConfigManager {
+	t.Helper()
+
+	filePath := path.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filePath, []byte(yamlConfig), 0o644); err != nil {
+		t.Fatalf("could not write config file: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	manager := config.CreateConfigManager(ctx, false, filePath, "")
+
+	t.Cleanup(func() {
+		cancel()
+		manager.Close()
+	})
+
+	return manager
+}
+
+func TestProxyMode(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(
+			res,
+			"%s?%s|%s|%s|%s",
+			req.URL.Path,
+			req.URL.RawQuery,
+			req.Header.Get("X-Forwarded-Host"),
+			req.Header.Get("X-Forwarded-Proto"),
+			req.Header.Get("Authorization"),
+		)
+	}))
+	defer upstream.Close()
+
+	manager := createTestConfigManager(t, fmt.Sprintf(`
+auth:
+  basic-auth:
+    admins:
+      users:
+        - username: admin
+          password: secret
+
+redirects:
+  - from: dashboard.example.com
+    to: %s
+    mode: proxy
+    auth: [admins]
+`, upstream.URL))
+
+	handler := getRedirectionMux(manager)
+
+	// Test unauthorized request is not forwarded
+	req := httptest.NewRequest("GET", "http://dashboard.example.com/some/path?a=1", nil)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+
+	// Test authorized request is forwarded without credentials
+	req = httptest.NewRequest("GET", "http://dashboard.example.com/some/path?a=1", nil)
+	req.SetBasicAuth("admin", "secret")
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, res.Code)
+	}
+
+	body, _ := io.ReadAll(res.Body)
+	expected := "/some/path?a=1|dashboard.example.com|http|"
+	if string(body) != expected {
+		t.Errorf("got %q, expected %q", body, expected)
+	}
+}
+
+func TestAuthLockout(t *testing.T) {
+	manager := createTestConfigManager(t, `
+auth:
+  protection:
+    max-ip-failures: 0
+    max-user-failures: 2
+    delay: 1ms
+    lockout: 1m
+  basic-auth:
+    admins:
+      users:
+        - username: locked-admin
+          password: secret
+        - username: other-admin
+          password: secret
+
+redirects:
+  - from: locked.example.com
+    to: https://target.com
+    auth: [admins]
+`)
+
+	handler := getRedirectionMux(manager)
+
+	request := func(username, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://locked.example.com/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		return res
+	}
+
+	// Test requests without credentials are not counted
+	for i := 0; i < 3; i++ {
+		if res := request("", ""); res.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, res.Code)
+		}
+	}
+
+	if res := request("locked-admin", "wrong"); res.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+
+	// Test lockout on reaching max failures, even with the right password afterwards
+	res := request("locked-admin", "wrong")
+	if res.Code != http.StatusTooManyRequests || res.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected status %d with Retry-After 60, got %d with %q", http.StatusTooManyRequests, res.Code, res.Header().Get("Retry-After"))
+	}
+
+	if res := request("locked-admin", "secret"); res.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, res.Code)
+	}
+
+	// Test other users from the same client IP are not affected, as client IP lockout is disabled
+	if res := request("other-admin", "secret"); res.Code != http.StatusTemporaryRedirect {
+		t.Errorf("expected status %d, got %d", http.StatusTemporaryRedirect, res.Code)
+	}
+}
+
+func TestForwardAuth(t *testing.T) {
+	authServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Forwarded-Uri") != "/page?a=1" || !strings.HasPrefix(req.Header.Get("X-Forwarded-Host"), "sso") {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Only the configured request headers are sent
+		if req.Header.Get("X-Other") != "" {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if cookie, err := req.Cookie("session"); err == nil && cookie.Value == "valid" {
+			res.Header().Set("X-Auth-User", "alice")
+			res.Header().Set("X-Internal", "not-copied")
+			res.WriteHeader(http.StatusOK)
+			return
+		}
+
+		http.Redirect(res, req, "https://login.example.com/", http.StatusFound)
+	}))
+	defer authServer.Close()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(res, req.Header.Get("X-Auth-User"))
+	}))
+	defer upstream.Close()
+
+	manager := createTestConfigManager(t, fmt.Sprintf(`
+auth:
+  forward-auth:
+    sso:
+      address: %s
+      request-headers: [Cookie]
+      response-headers: [X-Auth-User]
+    down:
+      address: http://127.0.0.1:1
+
+redirects:
+  - from: sso.example.com
+    to: https://target.com
+    auth: [sso]
+  - from: sso-proxy.example.com
+    to: %s
+    mode: proxy
+    auth: [sso]
+  - from: down.example.com
+    to: https://target.com
+    auth: [down]
+`, authServer.URL, upstream.URL))
+
+	handler := getRedirectionMux(manager)
+
+	request := func(url, session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("X-Other", "value")
+		req.Header.Set("X-Auth-User", "spoofed")
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: session})
+		}
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		return res
+	}
+
+	// Test denied request gets the endpoint response
+	res := request("http://sso.example.com/page?a=1", "invalid")
+	if res.Code != http.StatusFound || res.Header().Get("Location") != "https://login.example.com/" {
+		t.Errorf("expected redirect to login, got %d to %q", res.Code, res.Header().Get("Location"))
+	}
+
+	// Test allowed request is redirected with the configured response headers
+	res = request("http://sso.example.com/page?a=1", "valid")
+	if res.Code != http.StatusTemporaryRedirect || res.Header().Get("X-Auth-User") != "alice" || res.Header().Get("X-Internal") != "" {
+		t.Errorf("expected redirect with auth headers, got %d with %v", res.Code, res.Header())
+	}
+
+	// Test allowed request is proxied with the response headers replacing the client ones
+	res = request("http://sso-proxy.example.com/page?a=1", "valid")
+	if body, _ := io.ReadAll(res.Body); res.Code != http.StatusOK || string(body) != "alice" {
+		t.Errorf("expected upstream to get auth user, got %d with %q", res.Code, body)
+	}
+
+	// Test unreachable endpoint
+	if res := request("http://down.example.com/", "valid"); res.Code != http.StatusBadGateway {
+		t.Errorf("expected status %d, got %d", http.StatusBadGateway, res.Code)
+	}
+}
+
+func TestOIDC(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	var provider *httptest.Server
+	provider = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(
+				res,
+				`{"issuer": %q, "authorization_endpoint": %q, "token_endpoint": %q, "jwks_uri": %q}`,
+				provider.URL, provider.URL+"/authorize", provider.URL+"/token", provider.URL+"/jwks",
+			)
+
+		case "/jwks":
+			fmt.Fprintf(
+				res,
+				`{"keys": [{"kty": "RSA", "kid": "key", "n": %q, "e": "AQAB"}]}`,
+				base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			)
+
+		case "/token":
+			// Code is the user email and the login nonce, so that the mock provider keeps no state
+			clientID, clientSecret, _ := req.BasicAuth()
+			email, nonce, _ := strings.Cut(req.PostFormValue("code"), "|")
+			if clientID != "redirector" || clientSecret != "client-secret" || req.PostFormValue("code_verifier") == "" {
+				res.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			idToken, _ := jwttest.Sign(jwt.Claims{
+				"iss":            provider.URL,
+				"aud":            "redirector",
+				"sub":            "user-" + email,
+				"email":          email,
+				"email_verified": true,
+				"nonce":          nonce,
+				"exp":            time.Now().Add(time.Hour).Unix(),
+			}, "RS256", "key", key)
+
+			fmt.Fprintf(res, `{"id_token": %q}`, idToken)
+
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer provider.Close()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(res, req.Header.Get("Cookie"))
+	}))
+	defer upstream.Close()
+
+	manager := createTestConfigManager(t, fmt.Sprintf(`
+auth:
+  oidc:
+    staff:
+      issuer: %s
+      client-id: redirector
+      client-secret: client-secret
+      cookie-secret: cookie-secret
+      allowed-email-domains: [example.com]
+
+redirects:
+  - from: docs.example.com
+    to: https://target.com
+    auth: [staff]
+  - from: other.example.com
+    to: https://target.com
+    auth: [staff]
+  - from: app.example.com
+    to: %s
+    mode: proxy
+    auth: [staff]
+  - from: public.example.com
+    to: https://target.com
+  - from: scoped.example.com
+    path: /private
+    to: https://target.com
+    auth: [staff]
+`, provider.URL, upstream.URL))
+
+	handler := getRedirectionMux(manager)
+
+	request := func(url string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		return res
+	}
+
+	getCookie := func(res *httptest.ResponseRecorder, name string) *http.Cookie {
+		for _, cookie := range res.Result().Cookies() {
+			if cookie.Name == name && cookie.Value != "" {
+				return cookie
+			}
+		}
+
+		return nil
+	}
+
+	// Logs in with the mock provider from the given page, and returns the callback response
+	loginFrom := func(host, page, email string) *httptest.ResponseRecorder {
+		res := request("http://" + host + page)
+		loginUrl, _ := url.Parse(res.Header().Get("Location"))
+		if res.Code != http.StatusFound || !strings.HasPrefix(loginUrl.String(), provider.URL+"/authorize") {
+			t.Fatalf("expected redirect to provider, got %d to %q", res.Code, loginUrl)
+		}
+
+		query := loginUrl.Query()
+		if query.Get("redirect_uri") != "http://"+host+"/_redirector/oidc/callback" || query.Get("code_challenge_method") != "S256" {
+			t.Fatalf("unexpected login URL: %s", loginUrl)
+		}
+
+		// Login state holds the PKCE verifier, so it must not be readable by the client
+		stateCookie := getCookie(res, oidcStateCookieName)
+		if decoded, _ := base64.RawURLEncoding.DecodeString(strings.Split(stateCookie.Value, ".")[0]); strings.Contains(string(decoded), "verifier") {
+			t.Fatalf("expected login state to be encrypted, got %q", decoded)
+		}
+
+		code := url.QueryEscape(email + "|" + query.Get("nonce"))
+		return request(
+			fmt.Sprintf("http://%s/_redirector/oidc/callback?state=%s&code=%s", host, query.Get("state"), code),
+			stateCookie,
+		)
+	}
+
+	login := func(host, email string) *httptest.ResponseRecorder {
+		return loginFrom(host, "/page?x=1", email)
+	}
+
+	// Test login sends the user back to the requested page with a session
+	res := login("docs.example.com", "alice@example.com")
+	session := getCookie(res, "redirector_session")
+	if res.Code != http.StatusFound || res.Header().Get("Location") != "/page?x=1" || session == nil {
+		t.Fatalf("expected redirect back with session, got %d to %q", res.Code, res.Header().Get("Location"))
+	}
+
+	if res := request("http://docs.example.com/page", session); res.Code != http.StatusTemporaryRedirect {
+		t.Errorf("expected status %d with session, got %d", http.StatusTemporaryRedirect, res.Code)
+	}
+
+	// Test session is scoped to the domain it was created for
+	if res := request("http://other.example.com/page", session); res.Code != http.StatusFound {
+		t.Errorf("expected session of another domain to be rejected, got %d", res.Code)
+	}
+
+	// Test tampered session is rejected
+	tampered := &http.Cookie{Name: session.Name, Value: "x" + session.Value}
+	if res := request("http://docs.example.com/page", tampered); res.Code != http.StatusFound {
+		t.Errorf("expected tampered session to be rejected, got %d", res.Code)
+	}
+
+	// Test users outside the allowed email domains are rejected
+	if res := login("docs.example.com", "bob@other.com"); res.Code != http.StatusForbidden || getCookie(res, "redirector_session") != nil {
+		t.Errorf("expected status %d without session, got %d", http.StatusForbidden, res.Code)
+	}
+
+	// Test callback without a matching login state
+	if res := request("http://docs.example.com/_redirector/oidc/callback?state=invalid&code=x"); res.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, res.Code)
+	}
+
+	// Test callback path is only handled for hosts whose matched redirect uses OIDC
+	if res := request("http://public.example.com/_redirector/oidc/callback?state=invalid&code=x"); res.Code != http.StatusTemporaryRedirect {
+		t.Errorf("expected callback path of a host without OIDC to be redirected, got %d", res.Code)
+	}
+
+	// Test callback of a redirect that only covers other paths of the host
+	if res := loginFrom("scoped.example.com", "/private/page", "alice@example.com"); res.Code != http.StatusFound || res.Header().Get("Location") != "/private/page" {
+		t.Errorf("expected redirect back to scoped page, got %d to %q", res.Code, res.Header().Get("Location"))
+	}
+
+	// Test session cookie is not sent to the upstream in proxy mode
+	appSession := getCookie(login("app.example.com", "alice@example.com"), "redirector_session")
+	res = request("http://app.example.com/", appSession, &http.Cookie{Name: "app", Value: "1"})
+	if body, _ := io.ReadAll(res.Body); res.Code != http.StatusOK || string(body) != "app=1" {
+		t.Errorf("expected upstream to get app cookies only, got %d with %q", res.Code, body)
+	}
+}
+
+func TestApiKeyAndJwtAuth(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(res, "%s|%s|%s", req.URL.RawQuery, req.Header.Get("X-API-Key"), req.Header.Get("Authorization"))
+	}))
+	defer upstream.Close()
+
+	manager := createTestConfigManager(t, fmt.Sprintf(`
+auth:
+  jwt:
+    ci:
+      secret: jwt-secret
+  api-key:
+    keys:
+      query-param: api_key
+      keys:
+        - name: ci
+          key: ci-key
+
+redirects:
+  - from: artifacts.example.com
+    to: %s
+    mode: proxy
+    auth: [ci, keys]
+`, upstream.URL))
+
+	handler := getRedirectionMux(manager)
+
+	request := func(url string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+
+		return res
+	}
+
+	// Test missing credentials prompt for a bearer token
+	res := request("http://artifacts.example.com/", nil)
+	if res.Code != http.StatusUnauthorized || res.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("expected bearer prompt, got %d with %v", res.Code, res.Header())
+	}
+
+	// Test credentials are not forwarded to the upstream
+	res = request("http://artifacts.example.com/build.zip?api_key=ci-key&v=2", nil)
+	if body, _ := io.ReadAll(res.Body); res.Code != http.StatusOK || string(body) != "v=2||" {
+		t.Errorf("expected api key to be removed, got %d with %q", res.Code, body)
+	}
+
+	res = request("http://artifacts.example.com/build.zip", map[string]string{"X-API-Key": "ci-key"})
+	if body, _ := io.ReadAll(res.Body); res.Code != http.StatusOK || string(body) != "||" {
+		t.Errorf("expected api key header to be removed, got %d with %q", res.Code, body)
+	}
+
+	token, _ := jwttest.Sign(jwt.Claims{"sub": "ci", "exp": time.Now().Add(time.Hour).Unix()}, "HS256", "", []byte("jwt-secret"))
+	res = request("http://artifacts.example.com/build.zip", map[string]string{"Authorization": "Bearer " + token})
+	if body, _ := io.ReadAll(res.Body); res.Code != http.StatusOK || string(body) != "||" {
+		t.Errorf("expected bearer token to be accepted and removed, got %d with %q", res.Code, body)
+	}
+
+	if res := request("http://artifacts.example.com/", map[string]string{"X-API-Key": "wrong"}); res.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+}
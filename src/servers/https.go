@@ -0,0 +1,49 @@
+package servers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/AmrSaber/redirector/src/config"
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"golang.org/x/crypto/acme"
+)
+
+func StartHttpsServer(ctx context.Context, configManager *config.ConfigManager) <-chan error {
+	doneChan := make(chan error)
+
+	go func() {
+		defer close(doneChan)
+
+		port := configManager.GetTlsPort()
+
+		server := http.Server{
+			Addr:    fmt.Sprintf(":%d", port),
+			Handler: getRedirectionMux(configManager),
+
+			// Certificates are looked up on each handshake, so they are updated with config reloads
+			TLSConfig: &tls.Config{
+				GetCertificate: configManager.GetCertificate,
+				NextProtos:     []string{"h2", "http/1.1", acme.ALPNProto},
+			},
+		}
+
+		// Close server on end of context
+		go func() {
+			<-ctx.Done()
+
+			logger.Infof("Stopping HTTPS server...")
+			_ = server.Shutdown(context.Background())
+			logger.Infof("HTTPS server stopped")
+		}()
+
+		logger.Infof("Server listening on https://localhost:%d", port)
+		if err := server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			doneChan <- fmt.Errorf("could not start https server: %w", err)
+		}
+	}()
+
+	return doneChan
+}
@@ -0,0 +1,95 @@
+package servers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/config"
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/metrics"
+)
+
+var (
+	hitsMetric = metrics.NewCounter(
+		"redirector_rule_hits_total",
+		"Number of authorized requests matched by each redirect rule",
+		"rule",
+	)
+
+	unauthorizedMetric = metrics.NewCounter(
+		"redirector_rule_unauthorized_total",
+		"Number of unauthorized requests matched by each redirect rule",
+		"rule",
+	)
+
+	lockedOutMetric = metrics.NewCounter(
+		"redirector_rule_locked_out_total",
+		"Number of requests rejected for each redirect rule because the client IP or username is locked out",
+		"rule",
+	)
+
+	authLockoutsMetric = metrics.NewCounter(
+		"redirector_auth_lockouts_total",
+		"Number of lockouts after repeated failed auth attempts, by kind (ip, user)",
+		"kind",
+	)
+
+	missesMetric = metrics.NewCounter(
+		"redirector_misses_total",
+		"Number of requests that did not match any redirect rule",
+	)
+
+	responsesMetric = metrics.NewCounter(
+		"redirector_responses_total",
+		"Number of responses by status code",
+		"code",
+	)
+
+	requestDurationMetric = metrics.NewHistogram(
+		"redirector_request_duration_seconds",
+		"Time taken to handle requests",
+		metrics.DefaultBuckets,
+	)
+)
+
+func StartMetricsServer(ctx context.Context, configManager *config.ConfigManager) <-chan error {
+	doneChan := make(chan error)
+
+	metrics.NewGaugeFunc(
+		"redirector_config_age_seconds",
+		"Time since the current config was loaded",
+		func() float64 { return time.Since(configManager.GetConfig().LoadedAt).Seconds() },
+	)
+
+	go func() {
+		defer close(doneChan)
+
+		options := configManager.GetMetricsOptions()
+
+		handler := http.NewServeMux()
+		handler.Handle(options.Path, metrics.Handler())
+
+		server := http.Server{
+			Addr:    fmt.Sprintf(":%d", options.Port),
+			Handler: handler,
+		}
+
+		// Close server on end of context
+		go func() {
+			<-ctx.Done()
+
+			logger.Infof("Stopping metrics server...")
+			_ = server.Shutdown(context.Background())
+			logger.Infof("Metrics server stopped")
+		}()
+
+		logger.Infof("Metrics available on http://localhost:%d%s", options.Port, options.Path)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			doneChan <- fmt.Errorf("could not start metrics server: %w", err)
+		}
+	}()
+
+	return doneChan
+}
@@ -0,0 +1,96 @@
+package servers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/models"
+	"github.com/AmrSaber/redirector/src/utils"
+)
+
+type requestInfoKey struct{}
+
+// Details filled in by the handler about how a request was handled
+type requestInfo struct {
+	redirect *models.Redirect
+	target   string
+}
+
+// Records metrics and access log of each request
+func instrumentHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		startedAt := time.Now()
+		recorder := &responseRecorder{ResponseWriter: res, status: http.StatusOK}
+
+		info := &requestInfo{}
+		req = req.WithContext(context.WithValue(req.Context(), requestInfoKey{}, info))
+
+		handler.ServeHTTP(recorder, req)
+
+		latency := time.Since(startedAt)
+
+		requestDurationMetric.Observe(latency.Seconds())
+		responsesMetric.Inc(strconv.Itoa(recorder.status))
+
+		entry := logger.AccessEntry{
+			Time:      startedAt,
+			ClientIP:  utils.StripPort(req.RemoteAddr),
+			Method:    req.Method,
+			Host:      req.Host,
+			Path:      req.URL.Path,
+			Query:     req.URL.RawQuery,
+			Protocol:  req.Proto,
+			Status:    recorder.status,
+			Target:    info.target,
+			Bytes:     recorder.bytes,
+			LatencyMs: float64(latency.Microseconds()) / 1000,
+			UserAgent: req.UserAgent(),
+			Referer:   req.Referer(),
+		}
+
+		if username, _, ok := req.BasicAuth(); ok {
+			entry.User = username
+		}
+
+		if info.redirect != nil {
+			index := info.redirect.GetIndex()
+			entry.RuleIndex = &index
+			entry.RuleName = info.redirect.GetName()
+		}
+
+		logger.LogAccess(entry)
+	})
+}
+
+// Saves the matched redirect and target of the request to be logged
+func setRequestInfo(req *http.Request, redirect *models.Redirect, target string) {
+	if info, ok := req.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.redirect = redirect
+		info.target = target
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (recorder *responseRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *responseRecorder) Write(body []byte) (int, error) {
+	n, err := recorder.ResponseWriter.Write(body)
+	recorder.bytes += n
+	return n, err
+}
+
+// Allows http.ResponseController to reach the underlying writer, used by the reverse proxy
+func (recorder *responseRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
@@ -0,0 +1,297 @@
+package servers
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/oidc"
+	"github.com/AmrSaber/redirector/src/models"
+)
+
+// Cookie that keeps the login state between the redirect to the provider and the callback
+const oidcStateCookieName = "redirector_oidc_state"
+
+// How long users have to log in with the provider
+const oidcLoginTimeout = 10 * time.Minute
+
+// Timeout of the calls to the provider
+const oidcProviderTimeout = 10 * time.Second
+
+// Providers are kept across config reloads, so that their metadata and keys are not fetched on every reload
+var oidcProviders = struct {
+	lock      sync.Mutex
+	providers map[string]*oidc.Provider
+}{providers: make(map[string]*oidc.Provider)}
+
+// Encrypts the login state, as it contains the PKCE verifier, and signs the sessions of schemas without a cookie secret, valid until restart
+var oidcProcessSecret = []byte(oidc.RandomString())
+
+type oidcLoginState struct {
+	Schema       string `json:"schema"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"verifier"`
+	ReturnTo     string `json:"return_to"`
+	ExpiresAt    int64  `json:"exp"`
+}
+
+type oidcSession struct {
+	Schema        string   `json:"schema"`
+	Issuer        string   `json:"iss"`
+	Host          string   `json:"host"`
+	Subject       string   `json:"sub"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified bool     `json:"email_verified,omitempty"`
+	Groups        []string `json:"groups,omitempty"`
+	ExpiresAt     int64    `json:"exp"`
+}
+
+// Checks the session cookie of the request, and sends users without a session to log in with the provider
+// Users that are logged in but not allowed by the schema restrictions get 403
+// Returns whether the request should proceed
+func authorizeOIDC(res http.ResponseWriter, req *http.Request, redirect *models.Redirect, auth *models.OIDCSchema) bool {
+	requestPath := path.Join(req.Host, req.URL.Path)
+
+	session := getOIDCSession(req, auth)
+	if session == nil {
+		unauthorizedMetric.Inc(redirect.GetName())
+		setRequestInfo(req, redirect, "")
+
+		startOIDCLogin(res, req, auth)
+		logRequest("Sending unauthenticated request to log in for host: %s", requestPath)
+		return false
+	}
+
+	if !auth.IsUserAllowed(session.Subject, session.Email, session.EmailVerified, session.Groups) {
+		unauthorizedMetric.Inc(redirect.GetName())
+		setRequestInfo(req, redirect, "")
+
+		http.Error(res, "Forbidden", http.StatusForbidden)
+		logRequest("Received forbidden request from user %q for host: %s", session.Subject, requestPath)
+		return false
+	}
+
+	// Session cookie is meant for redirector, not for the upstream
+	if redirect.Mode == models.MODE_PROXY {
+		removeCookie(req, auth.CookieName)
+	}
+
+	return true
+}
+
+// Returns the valid session of the request, or nil if there is none
+func getOIDCSession(req *http.Request, auth *models.OIDCSchema) *oidcSession {
+	cookie, err := req.Cookie(auth.CookieName)
+	if err != nil {
+		return nil
+	}
+
+	var session oidcSession
+	if err := oidc.VerifyValue(getOIDCCookieSecret(auth), cookie.Value, &session); err != nil {
+		return nil
+	}
+
+	// Sessions are only valid for the schema and domain they were created for
+	if session.Schema != auth.GetName() || session.Issuer != auth.Issuer || session.Host != req.Host {
+		return nil
+	}
+
+	if time.Now().Unix() >= session.ExpiresAt {
+		return nil
+	}
+
+	return &session
+}
+
+// Redirects the user to the provider login page, keeping the login state in a cookie
+func startOIDCLogin(res http.ResponseWriter, req *http.Request, auth *models.OIDCSchema) {
+	ctx, cancel := context.WithTimeout(req.Context(), oidcProviderTimeout)
+	defer cancel()
+
+	state := oidcLoginState{
+		Schema:       auth.GetName(),
+		State:        oidc.RandomString(),
+		Nonce:        oidc.RandomString(),
+		CodeVerifier: oidc.RandomString(),
+		ReturnTo:     req.URL.RequestURI(),
+		ExpiresAt:    time.Now().Add(oidcLoginTimeout).Unix(),
+	}
+
+	loginUrl, err := getOIDCProvider(auth.Issuer).AuthCodeURL(ctx, oidc.AuthParams{
+		ClientID:     auth.ClientID,
+		RedirectURI:  getOIDCRedirectURI(req, auth),
+		Scopes:       auth.Scopes,
+		State:        state.State,
+		Nonce:        state.Nonce,
+		CodeVerifier: state.CodeVerifier,
+	})
+	if err != nil {
+		logger.Errorf("Could not start OIDC login [@oidc %q]: %s", auth.GetName(), err)
+		http.Error(res, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+
+	value, err := oidc.SealValue(oidcProcessSecret, state)
+	if err != nil {
+		logger.Errorf("Could not seal OIDC login state: %s", err)
+		http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	setOIDCCookie(res, req, oidcStateCookieName, value, oidcLoginTimeout)
+	http.Redirect(res, req, loginUrl, http.StatusFound)
+}
+
+// Completes the login: validates the login state, exchanges the code for an ID token, and creates the session
+// Users are then sent back to the page they requested before logging in
+func handleOIDCCallback(res http.ResponseWriter, req *http.Request, auth *models.OIDCSchema) {
+	requestPath := path.Join(req.Host, req.URL.Path)
+
+	var state oidcLoginState
+	stateCookie, err := req.Cookie(oidcStateCookieName)
+	if err == nil {
+		err = oidc.OpenValue(oidcProcessSecret, stateCookie.Value, &state)
+	}
+
+	if err != nil || state.Schema != auth.GetName() || time.Now().Unix() >= state.ExpiresAt || req.URL.Query().Get("state") != state.State {
+		http.Error(res, "Invalid or expired login, please try again", http.StatusBadRequest)
+		logRequest("Received invalid OIDC callback for host: %s", requestPath)
+		return
+	}
+
+	// State cookie is only used once
+	setOIDCCookie(res, req, oidcStateCookieName, "", -1)
+
+	if providerError := req.URL.Query().Get("error"); providerError != "" {
+		http.Error(res, "Login failed: "+providerError, http.StatusUnauthorized)
+		logRequest("Received OIDC login error %q for host: %s", providerError, requestPath)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), oidcProviderTimeout)
+	defer cancel()
+
+	provider := getOIDCProvider(auth.Issuer)
+
+	idToken, err := provider.Exchange(ctx, oidc.ExchangeParams{
+		ClientID:     auth.ClientID,
+		ClientSecret: auth.GetClientSecret(),
+		RedirectURI:  getOIDCRedirectURI(req, auth),
+		Code:         req.URL.Query().Get("code"),
+		CodeVerifier: state.CodeVerifier,
+	})
+	if err != nil {
+		logger.Errorf("Could not complete OIDC login [@oidc %q]: %s", auth.GetName(), err)
+		http.Error(res, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, idToken, auth.ClientID, state.Nonce)
+	if err != nil {
+		logger.Warnf("Received invalid ID token [@oidc %q]: %s", auth.GetName(), err)
+		http.Error(res, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	emailVerified, _ := claims["email_verified"].(bool)
+	session := oidcSession{
+		Schema:        auth.GetName(),
+		Issuer:        auth.Issuer,
+		Host:          req.Host,
+		Subject:       claims.GetString("sub"),
+		Email:         claims.GetString("email"),
+		EmailVerified: emailVerified,
+		Groups:        claims.GetStrings(auth.GroupsClaim),
+		ExpiresAt:     time.Now().Add(auth.SessionDuration).Unix(),
+	}
+
+	if !auth.IsUserAllowed(session.Subject, session.Email, session.EmailVerified, session.Groups) {
+		http.Error(res, "Forbidden", http.StatusForbidden)
+		logRequest("Rejected OIDC login of user %q for host: %s", session.Subject, requestPath)
+		return
+	}
+
+	value, err := oidc.SignValue(getOIDCCookieSecret(auth), session)
+	if err != nil {
+		logger.Errorf("Could not sign OIDC session: %s", err)
+		http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	setOIDCCookie(res, req, auth.CookieName, value, auth.SessionDuration)
+
+	// Only local paths are accepted, so the callback cannot be used to redirect to other sites
+	returnTo := state.ReturnTo
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") {
+		returnTo = "/"
+	}
+
+	logRequest("User %q logged in for host: %s", session.Subject, requestPath)
+	http.Redirect(res, req, returnTo, http.StatusFound)
+}
+
+func getOIDCProvider(issuer string) *oidc.Provider {
+	oidcProviders.lock.Lock()
+	defer oidcProviders.lock.Unlock()
+
+	provider, ok := oidcProviders.providers[issuer]
+	if !ok {
+		provider = oidc.NewProvider(issuer, nil)
+		oidcProviders.providers[issuer] = provider
+	}
+
+	return provider
+}
+
+func getOIDCCookieSecret(auth *models.OIDCSchema) []byte {
+	if secret := auth.GetCookieSecret(); secret != "" {
+		return []byte(secret)
+	}
+
+	return oidcProcessSecret
+}
+
+// Returns the callback URL on the requested domain
+func getOIDCRedirectURI(req *http.Request, auth *models.OIDCSchema) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + req.Host + auth.CallbackPath
+}
+
+// Sets a cookie scoped to the requested domain only, a negative max age deletes the cookie
+func setOIDCCookie(res http.ResponseWriter, req *http.Request, name, value string, maxAge time.Duration) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(maxAge.Seconds()),
+	}
+
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+
+	http.SetCookie(res, cookie)
+}
+
+func removeCookie(req *http.Request, name string) {
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+
+	for _, cookie := range cookies {
+		if cookie.Name != name {
+			req.AddCookie(cookie)
+		}
+	}
+}
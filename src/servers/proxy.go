@@ -0,0 +1,40 @@
+package servers
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/AmrSaber/redirector/src/lib/logger"
+)
+
+// Forwards the request to the given target URL, keeping the request query
+func proxyRequest(res http.ResponseWriter, req *http.Request, target string, stripAuth bool) {
+	targetUrl, err := url.Parse(target)
+	if err != nil {
+		logger.Errorf("invalid proxy target %q: %s", target, err)
+		http.Error(res, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+
+	targetUrl.RawQuery = req.URL.RawQuery
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(proxyReq *httputil.ProxyRequest) {
+			proxyReq.Out.URL = targetUrl
+			proxyReq.Out.Host = targetUrl.Host
+			proxyReq.SetXForwarded()
+
+			// Credentials are meant for redirector, not for the upstream
+			if stripAuth {
+				proxyReq.Out.Header.Del("Authorization")
+			}
+		},
+		ErrorHandler: func(res http.ResponseWriter, req *http.Request, err error) {
+			logger.Errorf("error proxying request to %q: %s", target, err)
+			http.Error(res, "Bad Gateway", http.StatusBadGateway)
+		},
+	}
+
+	proxy.ServeHTTP(res, req)
+}
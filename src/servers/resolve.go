@@ -0,0 +1,27 @@
+package servers
+
+import (
+	"net/http"
+
+	"github.com/AmrSaber/redirector/src/config"
+	"github.com/AmrSaber/redirector/src/models"
+)
+
+// Resolves the request against the active rules without serving it, OIDC sessions are checked like in the redirection handler
+func ResolveRequest(resolver config.RequestResolver, req *http.Request) models.Resolution {
+	return resolver.Resolve(req, checkOIDCSession)
+}
+
+// Returns the status code the OIDC auth responds with for the request, or 0 if the request is allowed
+func checkOIDCSession(req *http.Request, auth *models.OIDCSchema) int {
+	session := getOIDCSession(req, auth)
+	if session == nil {
+		return http.StatusFound
+	}
+
+	if !auth.IsUserAllowed(session.Subject, session.Email, session.EmailVerified, session.Groups) {
+		return http.StatusForbidden
+	}
+
+	return 0
+}
@@ -0,0 +1,72 @@
+package servers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/AmrSaber/redirector/src/config"
+)
+
+func TestResolveRequest(t *testing.T) {
+	manager := createTestConfigManager(t, `
+auth:
+  basic-auth:
+    team:
+      users:
+        - username: alice
+          password: secret
+redirects:
+  - from: "*.example.com"
+    to: https://*.target.com
+    preserve-path: true
+    temp-redirect: false
+  - from: private.com
+    to: https://internal.com
+    auth: [team]
+  - from: proxy.com
+    to: http://backend.internal
+    mode: proxy
+  - from: form.com
+    to: https://target.com/done
+    status: 303
+`)
+
+	testCases := []struct {
+		url     string
+		headers map[string][]string
+
+		ruleIndex  int
+		authorized bool
+		target     string
+		status     int
+	}{
+		{"https://a.example.com/docs", nil, 0, true, "https://a.target.com/docs", http.StatusPermanentRedirect},
+		{"https://private.com", nil, 1, false, "https://internal.com", http.StatusUnauthorized},
+		{"https://private.com", map[string][]string{"Authorization": {"Basic YWxpY2U6c2VjcmV0"}}, 1, true, "https://internal.com", http.StatusTemporaryRedirect},
+		{"https://proxy.com/api", nil, 2, true, "http://backend.internal/api", 0},
+		{"https://form.com", nil, 3, true, "https://target.com/done", http.StatusSeeOther},
+		{"https://unknown.com", nil, -1, false, "", http.StatusNotFound},
+	}
+
+	for _, testCase := range testCases {
+		req, err := config.NewResolveRequest(testCase.url, testCase.headers)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		resolution := ResolveRequest(manager, req)
+
+		ruleIndex := -1
+		if resolution.RuleIndex != nil {
+			ruleIndex = *resolution.RuleIndex
+		}
+
+		if ruleIndex != testCase.ruleIndex || resolution.Matched != (ruleIndex != -1) {
+			t.Errorf("%s: expected rule #%d, got %+v", testCase.url, testCase.ruleIndex, resolution)
+		}
+
+		if resolution.Authorized != testCase.authorized || resolution.Target != testCase.target || resolution.Status != testCase.status {
+			t.Errorf("%s: unexpected resolution %+v", testCase.url, resolution)
+		}
+	}
+}
@@ -1,49 +1,92 @@
 package servers
 
 import (
-	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"os"
+	"os/user"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/AmrSaber/redirector/src/config"
 	"github.com/AmrSaber/redirector/src/lib/logger"
+	"github.com/AmrSaber/redirector/src/lib/protocol"
+	"github.com/AmrSaber/redirector/src/models"
 	"github.com/AmrSaber/redirector/src/utils"
 )
 
-func StartUnixSocketListener(ctx context.Context) <-chan error {
+// Connections are closed if no request is received within this time
+const SOCKET_IDLE_TIMEOUT = time.Minute
+
+// Each response frame must be written within this time
+const SOCKET_WRITE_TIMEOUT = 10 * time.Second
+
+// Handles a socket command, intermediate results can be streamed using send, and the returned value is sent as the last frame
+type socketCommand func(request protocol.Request, send func(data any) error) (any, error)
+
+type SocketOptions struct {
+	Path string
+
+	// File mode of the socket, left as created if 0
+	Mode os.FileMode
+
+	// Owner of the socket in the form of "user", "user:group" or ":group", left as created if empty
+	Owner string
+}
+
+func StartUnixSocketListener(ctx context.Context, configManager *config.ConfigManager, options SocketOptions) <-chan error {
 	ctx, cancel := context.WithCancel(ctx)
 	doneChan := make(chan error)
+	startedAt := time.Now()
+
+	commands := getSocketCommands(configManager, startedAt)
+	socketPath := options.Path
 
 	go func() {
 		defer close(doneChan)
 
-		err := os.RemoveAll(utils.SOCKET_PATH)
+		// Do not take over the socket of another running instance
+		if conn, err := net.DialTimeout("unix", socketPath, time.Second); err == nil {
+			conn.Close()
+			doneChan <- fmt.Errorf("socket %q is in use by another running instance, use a different socket path", socketPath)
+			return
+		}
+
+		err := os.RemoveAll(socketPath)
 		if err != nil {
 			doneChan <- fmt.Errorf("error clearing socket file: %v", err)
+			return
 		}
 
-		listener, err := net.Listen("unix", utils.SOCKET_PATH)
+		listener, err := net.Listen("unix", socketPath)
 		if err != nil {
 			doneChan <- fmt.Errorf("error creating socket: %v", err)
+			return
+		}
+
+		if err := applySocketPermissions(socketPath, options.Mode, options.Owner); err != nil {
+			_ = listener.Close()
+			doneChan <- fmt.Errorf("error setting socket permissions: %v", err)
+			return
 		}
 
 		// Close listener on end of context
 		go func() {
 			<-ctx.Done()
 
-			logger.Std.Println("Closing socket listener...")
+			logger.Infof("Closing socket listener...")
 			_ = listener.Close()
-			logger.Std.Println("Socket listener closed")
+			logger.Infof("Socket listener closed")
 
-			_ = os.Remove(utils.SOCKET_PATH)
+			_ = os.Remove(socketPath)
 		}()
 
 		// Accept and handle connections
-		logger.Std.Println("Listening on socket", utils.SOCKET_PATH)
+		logger.Infof("Listening on socket %s", socketPath)
 		for {
 			conn, err := listener.Accept()
 
@@ -52,39 +95,225 @@ func StartUnixSocketListener(ctx context.Context) <-chan error {
 			}
 
 			if err != nil {
-				logger.Err.Println("error accepting connection:", err)
+				logger.Errorf("error accepting connection: %s", err)
 				continue
 			}
 
-			go func(conn net.Conn) {
-				defer conn.Close()
+			go handleSocketConnection(conn, commands, cancel)
+		}
+	}()
+
+	return doneChan
+}
+
+func applySocketPermissions(socketPath string, mode os.FileMode, owner string) error {
+	if mode != 0 {
+		if err := os.Chmod(socketPath, mode); err != nil {
+			return err
+		}
+	}
+
+	if owner == "" {
+		return nil
+	}
+
+	uid, gid := -1, -1
+	username, groupName, _ := strings.Cut(owner, ":")
+
+	if username != "" {
+		foundUser, err := user.Lookup(username)
+		if err != nil {
+			return err
+		}
+
+		uid, _ = strconv.Atoi(foundUser.Uid)
+	}
+
+	if groupName != "" {
+		foundGroup, err := user.LookupGroup(groupName)
+		if err != nil {
+			return err
+		}
+
+		gid, _ = strconv.Atoi(foundGroup.Gid)
+	}
+
+	return os.Chown(socketPath, uid, gid)
+}
+
+// Serves requests on the connection one after the other until it's closed or idle
+func handleSocketConnection(netConn net.Conn, commands map[string]socketCommand, stop func()) {
+	defer netConn.Close()
+
+	conn := protocol.NewConn(netConn)
+
+	for {
+		netConn.SetReadDeadline(time.Now().Add(SOCKET_IDLE_TIMEOUT))
+
+		request, err := conn.ReadRequest()
+		if err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+
+			// The stream cannot be recovered after a malformed request, so report and close
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				netConn.SetWriteDeadline(time.Now().Add(SOCKET_WRITE_TIMEOUT))
+				conn.WriteError("", protocol.NewError(protocol.ERROR_INVALID_REQUEST, "could not parse request: %s", err))
+			}
+
+			return
+		}
+
+		// Commands can run for as long as they need
+		netConn.SetReadDeadline(time.Time{})
+
+		send := func(data any) error {
+			netConn.SetWriteDeadline(time.Now().Add(SOCKET_WRITE_TIMEOUT))
+			return conn.WriteData(request.ID, data, false)
+		}
+
+		result, protocolErr := runSocketCommand(request, commands, send)
+
+		netConn.SetWriteDeadline(time.Now().Add(SOCKET_WRITE_TIMEOUT))
+		if protocolErr != nil {
+			logger.Warnf("socket command %q failed: %s", request.Command, protocolErr)
+			err = conn.WriteError(request.ID, protocolErr)
+		} else {
+			err = conn.WriteData(request.ID, result, true)
+		}
+
+		if err != nil {
+			logger.Errorf("error writing to socket connection: %s", err)
+			return
+		}
+
+		// Stop after the response is written so that the client receives it
+		if request.Command == protocol.COMMAND_STOP && protocolErr == nil {
+			stop()
+			return
+		}
+	}
+}
+
+func runSocketCommand(request protocol.Request, commands map[string]socketCommand, send func(data any) error) (any, *protocol.Error) {
+	if request.Version != protocol.VERSION {
+		return nil, protocol.NewError(
+			protocol.ERROR_UNSUPPORTED_VERSION,
+			"unsupported protocol version %d, expected %d", request.Version, protocol.VERSION,
+		)
+	}
+
+	command, ok := commands[request.Command]
+	if !ok {
+		return nil, protocol.NewError(protocol.ERROR_UNKNOWN_COMMAND, "unknown command %q", request.Command)
+	}
+
+	result, err := command(request, send)
+	if err != nil {
+		var protocolErr *protocol.Error
+		if errors.As(err, &protocolErr) {
+			return nil, protocolErr
+		}
+
+		return nil, protocol.NewError(protocol.ERROR_COMMAND_FAILED, "%s", err)
+	}
+
+	return result, nil
+}
+
+func getSocketCommands(configManager *config.ConfigManager, startedAt time.Time) map[string]socketCommand {
+	return map[string]socketCommand{
+		protocol.COMMAND_PING: func(protocol.Request, func(any) error) (any, error) {
+			return "PONG", nil
+		},
+
+		protocol.COMMAND_STOP: func(protocol.Request, func(any) error) (any, error) {
+			return "OK", nil
+		},
+
+		protocol.COMMAND_RELOAD: func(protocol.Request, func(any) error) (any, error) {
+			if err := configManager.ReloadConfig(); err != nil {
+				return nil, fmt.Errorf("could not reload config: %w", err)
+			}
+
+			logger.Infof("Config reloaded over socket")
+			logger.Debugf("New config:\n\n%s", configManager.GetStringConfig())
+			return "OK", nil
+		},
 
-				// Connection will timeout in 2 seconds
-				conn.SetDeadline(time.Now().Add(2 * time.Second))
+		protocol.COMMAND_STATUS: func(protocol.Request, func(any) error) (any, error) {
+			return getStatus(configManager, startedAt), nil
+		},
 
-				reader := bufio.NewReader(conn)
+		protocol.COMMAND_LOG_LEVEL: func(request protocol.Request, _ func(any) error) (any, error) {
+			var args protocol.LogLevelArgs
+			if len(request.Args) > 0 {
+				if err := json.Unmarshal(request.Args, &args); err != nil {
+					return nil, protocol.NewError(protocol.ERROR_INVALID_ARGS, "could not parse args: %s", err)
+				}
+			}
 
-				command, err := reader.ReadString('\n')
-				if err != nil {
-					logger.Err.Println("error reading from socket connection:", err)
-					return
+			if args.Level != "" {
+				if err := logger.SetLevel(args.Level); err != nil {
+					return nil, protocol.NewError(protocol.ERROR_INVALID_ARGS, "%s", err)
 				}
 
-				command = strings.TrimSpace(command)
-				switch command {
-				case utils.SOCKET_MESSAGE_PING:
-					conn.Write([]byte("PONG"))
+				logger.Infof("Log level changed over socket to %s", logger.GetLevel())
+			}
+
+			return logger.GetLevel(), nil
+		},
+
+		protocol.COMMAND_RESOLVE: func(request protocol.Request, _ func(any) error) (any, error) {
+			var args protocol.ResolveArgs
+			if err := json.Unmarshal(request.Args, &args); err != nil {
+				return nil, protocol.NewError(protocol.ERROR_INVALID_ARGS, "could not parse args: %s", err)
+			}
+
+			req, err := config.NewResolveRequest(args.URL, args.Headers)
+			if err != nil {
+				return nil, protocol.NewError(protocol.ERROR_INVALID_ARGS, "%s", err)
+			}
 
-				case utils.SOCKET_MESSAGE_STOP:
-					conn.Write([]byte("OK"))
-					cancel()
+			return ResolveRequest(configManager, req), nil
+		},
 
-				default:
-					logger.Err.Printf("unknown socket message: %q\n", command)
+		// Rules are streamed one per frame as they can be many
+		protocol.COMMAND_RULES: func(_ protocol.Request, send func(any) error) (any, error) {
+			for _, redirect := range configManager.GetConfig().Redirects {
+				if err := send(redirect); err != nil {
+					return nil, err
 				}
-			}(conn)
-		}
-	}()
+			}
 
-	return doneChan
+			return nil, nil
+		},
+	}
+}
+
+func getStatus(configManager *config.ConfigManager, startedAt time.Time) models.Status {
+	currentConfig := configManager.GetConfig()
+
+	version := utils.GetVersion()
+	if version == "" {
+		version = "??"
+	}
+
+	status := models.Status{
+		Version:    version,
+		Uptime:     time.Since(startedAt).Round(time.Second).String(),
+		Source:     strings.TrimPrefix(currentConfig.Source, "@source:"),
+		ConfigURI:  currentConfig.ConfigURI,
+		LoadedAt:   currentConfig.LoadedAt,
+		RulesCount: len(currentConfig.Redirects),
+	}
+
+	loadStatus := configManager.GetLoadStatus()
+	status.FailedLoads = loadStatus.FailedLoads
+	if loadStatus.LastError != nil {
+		status.LastLoadError = loadStatus.LastError.Error()
+		status.LastLoadErrorAt = &loadStatus.LastErrorAt
+	}
+
+	return status
 }
@@ -0,0 +1,104 @@
+package servers
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/AmrSaber/redirector/src/lib/protocol"
+)
+
+func TestSocketProtocol(t *testing.T) {
+	manager := createTestConfigManager(t, `
+redirects:
+  - from: a.example.com
+    to: https://a.com
+  - from: b.example.com
+    to: https://b.com
+`)
+
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	stopped := make(chan any)
+	go handleSocketConnection(serverConn, getSocketCommands(manager, time.Now()), func() { close(stopped) })
+
+	conn := protocol.NewConn(clientConn)
+
+	// Test several requests over the same connection
+	var pong string
+	err := conn.Call(protocol.COMMAND_PING, nil, func(data json.RawMessage) error { return json.Unmarshal(data, &pong) })
+	if err != nil || pong != "PONG" {
+		t.Errorf("expected PONG, got %q (error: %v)", pong, err)
+	}
+
+	// Test streaming
+	froms := []string{}
+	err = conn.Call(protocol.COMMAND_RULES, nil, func(data json.RawMessage) error {
+		var rule struct {
+			From string `json:"from"`
+		}
+
+		err := json.Unmarshal(data, &rule)
+		froms = append(froms, rule.From)
+		return err
+	})
+	if err != nil || len(froms) != 2 || froms[0] != "a.example.com" || froms[1] != "b.example.com" {
+		t.Errorf("expected 2 rules, got %v (error: %v)", froms, err)
+	}
+
+	// Test unknown command
+	err = conn.Call("unknown", nil, func(json.RawMessage) error { return nil })
+
+	var protocolErr *protocol.Error
+	if !errors.As(err, &protocolErr) || protocolErr.Code != protocol.ERROR_UNKNOWN_COMMAND {
+		t.Errorf("expected %q error, got %v", protocol.ERROR_UNKNOWN_COMMAND, err)
+	}
+
+	// Test stop
+	err = conn.Call(protocol.COMMAND_STOP, nil, func(json.RawMessage) error { return nil })
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Errorf("expected server to stop")
+	}
+}
+
+func TestSocketProtocolVersion(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	go handleSocketConnection(serverConn, map[string]socketCommand{}, func() {})
+
+	encoder := json.NewEncoder(clientConn)
+	decoder := json.NewDecoder(clientConn)
+
+	encoder.Encode(protocol.Request{Version: protocol.VERSION + 1, ID: "1", Command: protocol.COMMAND_PING})
+
+	var response protocol.Response
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if response.ID != "1" || !response.Done || response.Error == nil || response.Error.Code != protocol.ERROR_UNSUPPORTED_VERSION {
+		t.Errorf("expected %q error, got %+v", protocol.ERROR_UNSUPPORTED_VERSION, response)
+	}
+
+	// Test malformed request, pipes are synchronous so the server can respond before reading all of it
+	go clientConn.Write([]byte("not json\n"))
+
+	response = protocol.Response{}
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if response.Error == nil || response.Error.Code != protocol.ERROR_INVALID_REQUEST {
+		t.Errorf("expected %q error, got %+v", protocol.ERROR_INVALID_REQUEST, response)
+	}
+}
@@ -4,16 +4,26 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"time"
 )
 
 const DEFAULT_REALM = "Restricted"
 
-var SOCKET_PATH = path.Join(os.TempDir(), "redirector.sock")
+const DEFAULT_OIDC_CALLBACK_PATH = "/_redirector/oidc/callback"
 
-const SOCKET_MESSAGE_PING = "@redirector:PING"
-const SOCKET_MESSAGE_STOP = "@redirector:STOP"
+const DEFAULT_ACME_DIRECTORY = "https://acme-v02.api.letsencrypt.org/directory"
+
+var DEFAULT_SOCKET_PATH = path.Join(os.TempDir(), "redirector.sock")
+
+const SOCKET_ENV_NAME = "REDIRECTOR_SOCKET"
+
+// Reloading config from a URL can take longer than other socket messages
+const SOCKET_RELOAD_TIMEOUT = 30 * time.Second
 
 // Regex
-var DomainRegex = regexp.MustCompile(`^(?:[a-zA-Z0-9-_]+|\*)(?:\.(?:[a-zA-Z0-9-_]+|\*))+$`)
-var UrlRegex = regexp.MustCompile(`^\w+://(?:[a-zA-Z0-9-_]+|\*)(?:\.(?:[a-zA-Z0-9-_]+|\*))+(?:/[^/]*)*$`)
+var DomainRegex = regexp.MustCompile(`^(?:[a-zA-Z0-9-_]+|\*\*?)(?:\.(?:[a-zA-Z0-9-_]+|\*\*?))+$`)
+var UrlRegex = regexp.MustCompile(`^\w+://(?:[a-zA-Z0-9-_]+|\*\*?)(?:\.(?:[a-zA-Z0-9-_]+|\*\*?))+(?::\d+)?(?:/[^/]*)*$`)
 var HasPathRegex = regexp.MustCompile(`^.+//.+(?:/[^/]*)+$`)
+var RegexReferenceRegex = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+var CookieNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+var HeaderNameRegex = regexp.MustCompile("^[a-zA-Z0-9!#$%&'*+.^_`|~-]+$")
@@ -1,6 +1,10 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+	"strings"
+)
 
 func GetMapKeys[K comparable, V any](m map[K]V) []K {
 	keys := make([]K, 0, len(m))
@@ -32,3 +36,34 @@ func MapSlice[S any, T any](s []S, mapper func(S) T) []T {
 func ToStringSlice[S any](s []S) []string {
 	return MapSlice(s, func(value S) string { return fmt.Sprint(value) })
 }
+
+// Removes the port (if any) from the given host
+func StripPort(host string) string {
+	// Most hosts have no port, which is checked without the error allocation of SplitHostPort
+	if !strings.Contains(host, ":") {
+		return host
+	}
+
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		return hostname
+	}
+
+	return host
+}
+
+// Counts the occurrences of the given value in the slice
+func CountSlice[T comparable](s []T, value T) int {
+	count := 0
+	for _, item := range s {
+		if item == value {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Returns a pointer to a copy of the value
+func ToPointer[T any](value T) *T {
+	return &value
+}